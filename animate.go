@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/veandco/go-sdl2/sdl"
+	"image"
+	"image/color"
+	"mazes/path"
+	"sync"
+)
+
+// errWindowClosed is returned by play when the window is closed before the solver is done
+var errWindowClosed = errors.New("window closed")
+
+// milliseconds between frames of an animation
+const frameDelay = 16
+
+var (
+	expandedColor = color.RGBA{R: 0x60, G: 0x90, B: 0xFF, A: 0xFF}
+	frontierColor = color.RGBA{R: 0x40, G: 0xE0, B: 0x60, A: 0xFF}
+)
+
+// animation draws the nodes a solver expands and its frontier onto view as it
+// searches, pausing every perFrame nodes until the window shows a frame
+type animation struct {
+	// mu guards view, the solver draws on it while the window shows it
+	mu       sync.Mutex
+	view     *image.RGBA
+	scale    int
+	perFrame int
+	steps    int
+	frame    chan struct{}
+}
+
+func newAnimation(view *image.RGBA, scale, perFrame int) *animation {
+	return &animation{view: view, scale: scale, perFrame: perFrame, frame: make(chan struct{})}
+}
+
+// context adds a trace drawing the steps of the solver to ctx, pixels are the
+// pixels of the image a node stands for. Without an animation it's ctx. The
+// trace stops waiting for frames once ctx is done.
+func (a *animation) context(ctx context.Context, pixels func(path.Node) image.Rectangle) context.Context {
+	if a == nil {
+		return ctx
+	}
+	return path.WithTrace(ctx, &path.Trace{Expanded: func(step path.Step) {
+		a.mu.Lock()
+		for _, n := range step.Pushed {
+			a.fill(pixels(n), frontierColor)
+		}
+		a.fill(pixels(step.Node), expandedColor)
+		a.mu.Unlock()
+
+		a.steps++
+		if a.steps%a.perFrame == 0 {
+			select {
+			case <-a.frame:
+			case <-ctx.Done():
+			}
+		}
+	}})
+}
+
+// play runs solve on another goroutine, showing the frames it draws in window
+// until it's done. The context solve gets is canceled when play returns
+// early, so the solver doesn't wait for frames forever.
+func (a *animation) play(window *sdl.Window, solve func(ctx context.Context) ([]image.Point, error)) ([]image.Point, error) {
+	type solved struct {
+		solution []image.Point
+		err      error
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan solved, 1)
+	go func() {
+		solution, err := solve(ctx)
+		done <- solved{solution, err}
+	}()
+
+	for {
+		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			if _, ok := event.(*sdl.QuitEvent); ok {
+				return nil, errWindowClosed
+			}
+		}
+		a.mu.Lock()
+		err := showImage(window, a.view)
+		a.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case a.frame <- struct{}{}:
+		case s := <-done:
+			return s.solution, s.err
+		}
+		sdl.Delay(frameDelay)
+	}
+}
+
+// fill colors the pixels of view covering r, a rectangle of the whole image
+func (a *animation) fill(r image.Rectangle, c color.RGBA) {
+	r = image.Rect(r.Min.X/a.scale, r.Min.Y/a.scale, (r.Max.X+a.scale-1)/a.scale, (r.Max.Y+a.scale-1)/a.scale)
+	r = r.Intersect(a.view.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			a.view.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// pixelRect is the rectangle of the single pixel p
+func pixelRect(p image.Point) image.Rectangle {
+	return image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))}
+}
@@ -0,0 +1,20 @@
+package grid
+
+// Bitset is a set of integers from 0 to the size it was made with, one bit each
+type Bitset []uint64
+
+func NewBitset(size int) Bitset {
+	return make(Bitset, (size+63)/64)
+}
+
+func (b Bitset) Has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+func (b Bitset) Set(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b Bitset) Clear(i int) {
+	b[i/64] &^= 1 << (i % 64)
+}
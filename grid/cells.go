@@ -0,0 +1,253 @@
+package grid
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Lattice is how the cells of a maze are laid out in its pixels. Walls are
+// WallThickness pixels wide and cells CellSize pixels wide, repeating every
+// CellSize+WallThickness pixels starting with a wall at Offset.
+type Lattice struct {
+	CellSize, WallThickness int
+	Offset                  image.Point
+	// Cols and Rows are the number of whole cells in the image
+	Cols, Rows int
+}
+
+// posts are the pixels where walls cross, this many of them have to be walls to trust a lattice
+const minPostWallRatio = 0.95
+
+// DetectLattice finds the cell size, wall thickness and offset of the maze in
+// g. The narrowest corridor gives the cell size and the thinnest wall the wall
+// thickness, so every cell has to be drawn the same size.
+func DetectLattice(g *Grid) (Lattice, error) {
+	minOpen, minWall := 0, 0
+	// runs touching the edges of the image can be cut, they aren't counted
+	addRun := func(open bool, length int) {
+		if open && (minOpen == 0 || length < minOpen) {
+			minOpen = length
+		}
+		if !open && (minWall == 0 || length < minWall) {
+			minWall = length
+		}
+	}
+	colWalls := make([]int, g.Width)
+	rowWalls := make([]int, g.Height)
+	for y := 0; y < g.Height; y++ {
+		start := 0
+		for x := 1; x <= g.Width; x++ {
+			if !g.Open(x-1, y) {
+				colWalls[x-1]++
+				rowWalls[y]++
+			}
+			if x < g.Width && g.Open(x, y) == g.Open(start, y) {
+				continue
+			}
+			if start > 0 && x < g.Width {
+				addRun(g.Open(start, y), x-start)
+			}
+			start = x
+		}
+	}
+	for x := 0; x < g.Width; x++ {
+		start := 0
+		for y := 1; y < g.Height; y++ {
+			if g.Open(x, y) == g.Open(x, start) {
+				continue
+			}
+			if start > 0 {
+				addRun(g.Open(x, start), y-start)
+			}
+			start = y
+		}
+	}
+	if minOpen == 0 || minWall == 0 {
+		return Lattice{}, errors.New("couldn't find a cell lattice, there are no corridors between walls")
+	}
+
+	period := minOpen + minWall
+	l := Lattice{
+		CellSize:      minOpen,
+		WallThickness: minWall,
+		Offset:        image.Pt(wallPhase(colWalls, period, minWall), wallPhase(rowWalls, period, minWall)),
+	}
+	l.Cols = (g.Width - l.Offset.X) / period
+	l.Rows = (g.Height - l.Offset.Y) / period
+	if l.Cols == 0 || l.Rows == 0 {
+		return Lattice{}, fmt.Errorf("couldn't find a cell lattice, %dx%d cells with %d pixel walls don't fit in the image", minOpen, minOpen, minWall)
+	}
+
+	posts, walls := 0, 0
+	for gy := 0; gy <= 2*l.Rows; gy += 2 {
+		for gx := 0; gx <= 2*l.Cols; gx += 2 {
+			p := l.ToPixel(image.Pt(gx, gy))
+			if p.X < g.Width && p.Y < g.Height {
+				posts++
+				if !g.Open(p.X, p.Y) {
+					walls++
+				}
+			}
+		}
+	}
+	if float64(walls) < minPostWallRatio*float64(posts) {
+		return Lattice{}, fmt.Errorf("couldn't find a cell lattice, only %d of %d wall crossings are walls", walls, posts)
+	}
+	return l, nil
+}
+
+// wallPhase is the offset at which walls of the given thickness repeating every
+// period pixels cover the most wall pixels, walls has the count for every column or row
+func wallPhase(walls []int, period, thickness int) int {
+	best, bestScore := 0, -1
+	for offset := 0; offset < period; offset++ {
+		score := 0
+		for i := offset; i < len(walls); i += period {
+			for j := i; j < i+thickness && j < len(walls); j++ {
+				score += walls[j]
+			}
+		}
+		if score > bestScore {
+			best, bestScore = offset, score
+		}
+	}
+	return best
+}
+
+// ToPixel maps a point of the cell grid, see CellMaze.Grid, to the pixel at
+// the center of the cell or of the wall it stands for
+func (l Lattice) ToPixel(p image.Point) image.Point {
+	return image.Pt(l.toPixel(p.X, l.Offset.X), l.toPixel(p.Y, l.Offset.Y))
+}
+
+func (l Lattice) toPixel(v, offset int) int {
+	pixel := offset + v/2*(l.CellSize+l.WallThickness)
+	if v%2 == 1 {
+		return pixel + l.WallThickness + l.CellSize/2
+	}
+	return pixel + l.WallThickness/2
+}
+
+// PixelRect is the pixels of the cell or wall a point of the cell grid stands for
+func (l Lattice) PixelRect(p image.Point) image.Rectangle {
+	x, w := l.pixelSpan(p.X, l.Offset.X)
+	y, h := l.pixelSpan(p.Y, l.Offset.Y)
+	return image.Rect(x, y, x+w, y+h)
+}
+
+func (l Lattice) pixelSpan(v, offset int) (int, int) {
+	pixel := offset + v/2*(l.CellSize+l.WallThickness)
+	if v%2 == 1 {
+		return pixel + l.WallThickness, l.CellSize
+	}
+	return pixel, l.WallThickness
+}
+
+// FromPixel maps a pixel to the point of the cell grid whose cell or wall it's in
+func (l Lattice) FromPixel(p image.Point) image.Point {
+	return image.Pt(l.fromPixel(p.X, l.Offset.X, l.Cols), l.fromPixel(p.Y, l.Offset.Y, l.Rows))
+}
+
+func (l Lattice) fromPixel(pixel, offset, cells int) int {
+	period := l.CellSize + l.WallThickness
+	rel := maxInt(pixel-offset, 0)
+	v := 2 * (rel / period)
+	if rel%period >= l.WallThickness {
+		v++
+	}
+	return minInt(v, 2*cells)
+}
+
+type Direction uint8
+
+const (
+	North Direction = 1 << iota
+	East
+	South
+	West
+)
+
+// CellMaze is a maze as cells with passages between them
+type CellMaze struct {
+	Lattice
+	// Grid has a pixel per cell and per wall between cells, cell x, y is at
+	// 2x+1, 2y+1 and the walls around it are the pixels next to it. It's what
+	// the cell maze should be solved on.
+	Grid *Grid
+}
+
+// Cells samples the cells and walls of g at the center of each of them
+func (l Lattice) Cells(g *Grid) *CellMaze {
+	m := &CellMaze{Lattice: l, Grid: New(2*l.Cols+1, 2*l.Rows+1)}
+	for gy := 0; gy < m.Grid.Height; gy++ {
+		for gx := 0; gx < m.Grid.Width; gx++ {
+			p := l.ToPixel(image.Pt(gx, gy))
+			m.Grid.SetOpen(gx, gy, g.Open(p.X, p.Y))
+		}
+	}
+	return m
+}
+
+// Passages are the sides of cell x, y without a wall, including the ones
+// leading out of the maze
+func (m *CellMaze) Passages(x, y int) Direction {
+	gx, gy := 2*x+1, 2*y+1
+	var d Direction
+	if m.Grid.Open(gx, gy-1) {
+		d |= North
+	}
+	if m.Grid.Open(gx+1, gy) {
+		d |= East
+	}
+	if m.Grid.Open(gx, gy+1) {
+		d |= South
+	}
+	if m.Grid.Open(gx-1, gy) {
+		d |= West
+	}
+	return d
+}
+
+// PixelPath maps a path on Grid to the pixels it crosses, going in straight
+// lines through the centers of cells and walls
+func (m *CellMaze) PixelPath(path []image.Point) []image.Point {
+	pixels := make([]image.Point, 0, len(path)*(m.CellSize+m.WallThickness)/2+1)
+	for i, p := range path {
+		to := m.ToPixel(p)
+		if i == 0 {
+			pixels = append(pixels, to)
+			continue
+		}
+		at := pixels[len(pixels)-1]
+		for at != to {
+			at = at.Add(image.Pt(sign(to.X-at.X), sign(to.Y-at.Y)))
+			pixels = append(pixels, at)
+		}
+	}
+	return pixels
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
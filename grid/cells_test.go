@@ -0,0 +1,220 @@
+package grid
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"os"
+	"slices"
+	"testing"
+)
+
+// randomCellGrid carves a perfect maze of cols x rows cells with a randomized
+// depth first search, with an entrance on the left and an exit on the right
+func randomCellGrid(r *rand.Rand, cols, rows int) *Grid {
+	g := New(2*cols+1, 2*rows+1)
+	visited := make([]bool, cols*rows)
+	stack := []image.Point{{0, 0}}
+	visited[0] = true
+	g.SetOpen(1, 1, true)
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		next := make([]image.Point, 0, 4)
+		for _, d := range []image.Point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+			n := c.Add(d)
+			if n.X >= 0 && n.Y >= 0 && n.X < cols && n.Y < rows && !visited[n.Y*cols+n.X] {
+				next = append(next, n)
+			}
+		}
+		if len(next) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		n := next[r.Intn(len(next))]
+		visited[n.Y*cols+n.X] = true
+		g.SetOpen(2*n.X+1, 2*n.Y+1, true)
+		g.SetOpen(c.X+n.X+1, c.Y+n.Y+1, true)
+		stack = append(stack, n)
+	}
+	g.SetOpen(0, 1, true)
+	g.SetOpen(2*cols, 2*rows-1, true)
+	return g
+}
+
+// renderCellGrid draws the cells of g as cellSize pixels and the walls between
+// them as wall pixels, padded with pad extra open pixels on the top left
+func renderCellGrid(g *Grid, cellSize, wall int, pad image.Point) *Grid {
+	size := func(v int) int {
+		if v%2 == 1 {
+			return cellSize
+		}
+		return wall
+	}
+	width, height := pad.X, pad.Y
+	for gx := 0; gx < g.Width; gx++ {
+		width += size(gx)
+	}
+	for gy := 0; gy < g.Height; gy++ {
+		height += size(gy)
+	}
+
+	pixels := New(width, height)
+	for y := 0; y < pad.Y; y++ {
+		for x := 0; x < width; x++ {
+			pixels.SetOpen(x, y, true)
+		}
+	}
+	y := pad.Y
+	for gy := 0; gy < g.Height; gy++ {
+		for dy := 0; dy < size(gy); dy++ {
+			for x := 0; x < pad.X; x++ {
+				pixels.SetOpen(x, y, true)
+			}
+			x := pad.X
+			for gx := 0; gx < g.Width; gx++ {
+				for dx := 0; dx < size(gx); dx++ {
+					pixels.SetOpen(x, y, g.Open(gx, gy))
+					x++
+				}
+			}
+			y++
+		}
+	}
+	return pixels
+}
+
+func TestDetectLatticeRendered(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tests := []struct {
+		cellSize, wall int
+		pad            image.Point
+	}{
+		{1, 1, image.Pt(0, 0)},
+		{3, 1, image.Pt(0, 0)},
+		{10, 10, image.Pt(0, 0)},
+		{7, 2, image.Pt(4, 0)},
+		{16, 5, image.Pt(3, 11)},
+		{2, 6, image.Pt(1, 1)},
+	}
+	for _, test := range tests {
+		cells := randomCellGrid(r, 13, 9)
+		pixels := renderCellGrid(cells, test.cellSize, test.wall, test.pad)
+
+		l, err := DetectLattice(pixels)
+		if err != nil {
+			t.Fatalf("%+v: expected no error, but got: %v", test, err)
+		}
+		expected := Lattice{CellSize: test.cellSize, WallThickness: test.wall, Offset: test.pad, Cols: 13, Rows: 9}
+		if l != expected {
+			t.Fatalf("Expected lattice %+v, but got: %+v", expected, l)
+		}
+		m := l.Cells(pixels)
+		if m.Grid.Width != cells.Width || m.Grid.Height != cells.Height || !slices.Equal(m.Grid.open, cells.open) {
+			t.Fatalf("%+v: expected the cell grid to be the one that was rendered", test)
+		}
+		for y := 0; y < pixels.Height; y++ {
+			for x := 0; x < pixels.Width; x++ {
+				p := m.FromPixel(image.Pt(x, y))
+				if x >= test.pad.X && y >= test.pad.Y && cells.Open(p.X, p.Y) != pixels.Open(x, y) {
+					t.Fatalf("%+v: expected pixel (%d, %d) to map to a grid point matching it, but got: %v", test, x, y, p)
+				}
+			}
+		}
+	}
+}
+
+func TestDetectLatticeSampleMazes(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Lattice
+	}{
+		{"../mazediag21x21.png", Lattice{CellSize: 1, WallThickness: 1, Cols: 10, Rows: 10}},
+		{"../download.png", Lattice{CellSize: 10, WallThickness: 10, Cols: 20, Rows: 20}},
+	}
+	for _, test := range tests {
+		data, err := os.ReadFile(test.name)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		g, err := FromReader(bytes.NewReader(data), nil)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		l, err := DetectLattice(g)
+		if err != nil {
+			t.Fatalf("%s: expected no error, but got: %v", test.name, err)
+		}
+		if l != test.expected {
+			t.Fatalf("%s: expected lattice %+v, but got: %+v", test.name, test.expected, l)
+		}
+	}
+}
+
+func TestDetectLatticeWithoutWalls(t *testing.T) {
+	g := New(10, 10)
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			g.SetOpen(x, y, x != 5 || y < 3)
+		}
+	}
+	if _, err := DetectLattice(g); err == nil {
+		t.Fatalf("Expected an error for an image without a lattice")
+	}
+}
+
+func TestPassagesAndPixelPath(t *testing.T) {
+	cells := gridFromStrings(
+		"#####",
+		"....#",
+		"###.#",
+		"#....",
+		"#####",
+	)
+	pixels := renderCellGrid(cells, 4, 2, image.Point{})
+	l, err := DetectLattice(pixels)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	m := l.Cells(pixels)
+
+	if got := m.Passages(0, 0); got != East|West {
+		t.Fatalf("Expected cell (0, 0) to have passages east and west, but got: %b", got)
+	}
+	if got := m.Passages(1, 1); got != North|East|West {
+		t.Fatalf("Expected cell (1, 1) to have passages north, east and west, but got: %b", got)
+	}
+
+	// cell (1, 1) and the wall west of it, 4 and 2 pixels wide
+	if got := l.PixelRect(image.Pt(3, 3)); got != image.Rect(8, 8, 12, 12) {
+		t.Fatalf("Expected the pixels of cell (1, 1) to be (8,8)-(12,12), but got: %v", got)
+	}
+	if got := l.PixelRect(image.Pt(2, 3)); got != image.Rect(6, 8, 8, 12) {
+		t.Fatalf("Expected the pixels of the wall west of cell (1, 1) to be (6,8)-(8,12), but got: %v", got)
+	}
+	for _, p := range []image.Point{{3, 3}, {2, 3}} {
+		if c := l.ToPixel(p); !c.In(l.PixelRect(p)) {
+			t.Fatalf("Expected %v to be in the pixels of %v", c, p)
+		}
+	}
+
+	path := []image.Point{{0, 1}, {1, 1}, {2, 1}, {3, 1}, {3, 2}, {3, 3}, {4, 3}}
+	pixelPath := m.PixelPath(path)
+	if pixelPath[0] != image.Pt(1, 4) || pixelPath[len(pixelPath)-1] != image.Pt(13, 10) {
+		t.Fatalf("Expected the path to go from (1,4) to (13,10), but got: %v to %v", pixelPath[0], pixelPath[len(pixelPath)-1])
+	}
+	for i, p := range pixelPath {
+		if !pixels.Open(p.X, p.Y) {
+			t.Fatalf("Expected pixel %v of the path to be open", p)
+		}
+		if d := p.Sub(pixelPath[maxInt(i-1, 0)]); absInt(d.X)+absInt(d.Y) > 1 {
+			t.Fatalf("Expected consecutive pixels of the path to be next to each other, but got: %v after %v", p, pixelPath[i-1])
+		}
+	}
+}
+
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
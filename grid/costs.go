@@ -0,0 +1,138 @@
+package grid
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	mazesPng "mazes/png"
+)
+
+// Cost is what stepping on a pixel costs, +Inf for walls. Costs have to be
+// positive for solvers to find the cheapest paths.
+type Cost func(p Pixel) float64
+
+// LuminanceCost makes white pixels cost lightest and black ones darkest, and
+// the ones in between cost in proportion. Translucent pixels are blended over
+// black first.
+func LuminanceCost(lightest, darkest float64) Cost {
+	return func(p Pixel) float64 {
+		return darkest + (lightest-darkest)*luminance(p.Color)
+	}
+}
+
+// ColorCost is the cost of pixels of exactly Color
+type ColorCost struct {
+	Color color.Color
+	Cost  float64
+}
+
+// ColorCosts gives pixels the cost of the first of costs with their color,
+// and other pixels the cost of other
+func ColorCosts(other Cost, costs ...ColorCost) Cost {
+	colors := make([]color.NRGBA64, len(costs))
+	for i, c := range costs {
+		colors[i] = nrgba64(c.Color)
+	}
+	return func(p Pixel) float64 {
+		for i, c := range colors {
+			if c == p.Color {
+				return costs[i].Cost
+			}
+		}
+		return other(p)
+	}
+}
+
+// PaletteCosts gives pixels of palette images the cost of their index in
+// costs, and other pixels the cost of other
+func PaletteCosts(other Cost, costs map[int]float64) Cost {
+	return func(p Pixel) float64 {
+		if cost, ok := costs[p.Index]; ok && p.Index >= 0 {
+			return cost
+		}
+		return other(p)
+	}
+}
+
+// OpenOnly makes the pixels rule considers walls impassable and gives the
+// others their cost
+func OpenOnly(rule Rule, cost Cost) Cost {
+	return func(p Pixel) float64 {
+		if !rule(p) {
+			return math.Inf(1)
+		}
+		return cost(p)
+	}
+}
+
+// CostMap is what stepping on each pixel of an image costs, row by row. It
+// takes 8 bytes per pixel, unlike the single bit of a Grid.
+type CostMap struct {
+	Width, Height int
+	Costs         []float64
+}
+
+func newCostMap(width, height int) *CostMap {
+	return &CostMap{Width: width, Height: height, Costs: make([]float64, width*height)}
+}
+
+// Grid opens the pixels that can be stepped on
+func (m *CostMap) Grid() *Grid {
+	g := New(m.Width, m.Height)
+	for i, cost := range m.Costs {
+		g.SetOpen(i%m.Width, i/m.Width, !math.IsInf(cost, 1))
+	}
+	return g
+}
+
+// CostsFromPng gives every pixel of p its cost
+func CostsFromPng(p *mazesPng.Png, cost Cost) (*CostMap, error) {
+	m := newCostMap(p.Width, p.Height)
+	for y, row := range p.Pixels {
+		for x, pixel := range row {
+			px, err := pngPixel(p, pixel)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't convert pixel (%d, %d): %w", x, y, err)
+			}
+			m.Costs[y*m.Width+x] = cost(px)
+		}
+	}
+	return m, nil
+}
+
+// CostsFromReader streams the png in r row by row, like FromReader
+func CostsFromReader(r io.Reader, cost Cost) (*CostMap, error) {
+	var m *CostMap
+	err := mazesPng.DecodeRows(r, func(y int, row mazesPng.RowView) error {
+		if m == nil {
+			m = newCostMap(row.Width(), row.Height())
+		}
+		for x := 0; x < row.Width(); x++ {
+			p, err := RowPixel(row, x)
+			if err != nil {
+				return fmt.Errorf("couldn't convert pixel (%d, %d): %w", x, y, err)
+			}
+			m.Costs[y*m.Width+x] = cost(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CostsFromImage gives every pixel of img its cost, the map starts at 0, 0
+// whatever the bounds of img are
+func CostsFromImage(img image.Image, cost Cost) *CostMap {
+	bounds := img.Bounds()
+	m := newCostMap(bounds.Dx(), bounds.Dy())
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			m.Costs[y*m.Width+x] = cost(imagePixel(img, bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return m
+}
@@ -0,0 +1,90 @@
+package grid
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	mazesPng "mazes/png"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestCosts(t *testing.T) {
+	gray := Pixel{Color: nrgba64(color.Gray{Y: 0x80}), Index: -1}
+	red := Pixel{Color: nrgba64(color.NRGBA{R: 0xFF, A: 0xFF}), Index: 3}
+	luminance := LuminanceCost(1, 10)
+	tests := []struct {
+		name     string
+		cost     Cost
+		pixel    Pixel
+		expected float64
+	}{
+		{"white", luminance, Pixel{Color: nrgba64(color.White), Index: -1}, 1},
+		{"black", luminance, Pixel{Color: nrgba64(color.Black), Index: -1}, 10},
+		{"transparent white", luminance, Pixel{Color: color.NRGBA64{R: 0xFFFF, G: 0xFFFF, B: 0xFFFF}, Index: -1}, 10},
+		{"gray", luminance, gray, 10 - 9*float64(0x8080)/0xFFFF},
+		{"matching color", ColorCosts(luminance, ColorCost{color.NRGBA{R: 0xFF, A: 0xFF}, math.Inf(1)}), red, math.Inf(1)},
+		{"other color", ColorCosts(luminance, ColorCost{color.NRGBA{R: 0xFF, A: 0xFF}, math.Inf(1)}), gray, luminance(gray)},
+		{"palette index", PaletteCosts(luminance, map[int]float64{3: 7}), red, 7},
+		{"not a palette", PaletteCosts(luminance, map[int]float64{-1: 7}), gray, luminance(gray)},
+		{"open", OpenOnly(LuminanceAbove(0.25), luminance), gray, luminance(gray)},
+		{"wall", OpenOnly(LuminanceAbove(0.75), luminance), gray, math.Inf(1)},
+	}
+	for _, test := range tests {
+		if got := test.cost(test.pixel); math.Abs(got-test.expected) > 1e-9 && got != test.expected {
+			t.Fatalf("%s: expected %v, but got: %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestCostsFromReaderMatchesCostsFromPng(t *testing.T) {
+	cost := OpenOnly(DefaultRule, LuminanceCost(1, 5))
+	for _, name := range []string{"../mazediag21x21.png", "../palette.png"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		p, err := mazesPng.DecodePng(data)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		expected, err := CostsFromPng(p, cost)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		got, err := CostsFromReader(bytes.NewReader(data), cost)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		if got.Width != expected.Width || got.Height != expected.Height || !slices.Equal(got.Costs, expected.Costs) {
+			t.Fatalf("%s: Expected CostsFromReader to match CostsFromPng", name)
+		}
+
+		// the walls of the cost map are the walls of the grid with the same rule
+		g, err := FromPng(p, DefaultRule)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		if !slices.Equal(got.Grid().open, g.open) {
+			t.Fatalf("%s: Expected the grid of the cost map to match FromPng", name)
+		}
+	}
+}
+
+func TestCostsFromImage(t *testing.T) {
+	img := image.NewGray(image.Rect(2, 3, 5, 5))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 0x33)
+	}
+	m := CostsFromImage(img, LuminanceCost(0, 0xFF))
+	if m.Width != 3 || m.Height != 2 {
+		t.Fatalf("Expected a 3x2 cost map, but got: %dx%d", m.Width, m.Height)
+	}
+	for i, cost := range m.Costs {
+		if expected := float64(0xFF - i*0x33); math.Abs(cost-expected) > 1e-9 {
+			t.Fatalf("%d expected a cost of %v, but got: %v", i, expected, cost)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package grid
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"slices"
+
+	mazesPng "mazes/png"
+)
+
+// Convention picks the start and end of a maze among the openings on its border
+type Convention int
+
+const (
+	// TopLeftBottomRight starts at the opening closest to the top left corner
+	// and ends at the one closest to the bottom right corner
+	TopLeftBottomRight Convention = iota
+	// Farthest uses the two openings farthest apart, starting from the one
+	// closer to the top left corner
+	Farthest
+)
+
+func (c Convention) String() string {
+	switch c {
+	case TopLeftBottomRight:
+		return "top-left/bottom-right"
+	case Farthest:
+		return "farthest"
+	}
+	return fmt.Sprintf("Convention(%d)", int(c))
+}
+
+var (
+	DefaultStartMarker color.Color = color.NRGBA{G: 0xFF, A: 0xFF}
+	DefaultEndMarker   color.Color = color.NRGBA{R: 0xFF, A: 0xFF}
+)
+
+// markers can be antialiased or slightly off, channels this close to the marker color still match
+const markerTolerance = 0x30 * 0x101
+
+type EndpointOptions struct {
+	Convention Convention
+	// Image is searched for start and end markers, a marker that's found wins
+	// over the border openings. Groups of marker colored pixels too big to be
+	// a marker, like walls, are left alone. nil only looks at the border of
+	// the grid.
+	Image image.Image
+	// Markers are the markers of an image too big to be kept in memory, found
+	// with MarkersFromReader. They're used instead of Image, and only once.
+	Markers *Markers
+	// StartMarker and EndMarker are the colors of the markers, nil uses DefaultStartMarker and DefaultEndMarker
+	StartMarker, EndMarker color.Color
+}
+
+// FindEndpoints picks the start and end of the maze in g. Markers are usually
+// drawn in colors the grid rule considers walls, so the pixels of the markers
+// that are found are opened in g.
+func FindEndpoints(g *Grid, opts EndpointOptions) (image.Point, image.Point, error) {
+	markers := opts.Markers
+	if markers == nil && opts.Image != nil {
+		markers = markersFromImage(opts.Image, g.Width, g.Height, opts)
+	}
+
+	var start, end image.Point
+	hasStart, hasEnd := false, false
+	if markers != nil {
+		if markers.width != g.Width || markers.height != g.Height {
+			return image.Point{}, image.Point{}, fmt.Errorf("markers are for a %dx%d image, but the grid is %dx%d", markers.width, markers.height, g.Width, g.Height)
+		}
+		points, found := markers.open(g)
+		start, end = points[0], points[1]
+		hasStart, hasEnd = found[0], found[1]
+	}
+	if hasStart && hasEnd {
+		return start, end, nil
+	}
+
+	openings := g.Openings()
+	topLeft := func(p image.Point) int { return -p.X - p.Y }
+	bottomRight := func(p image.Point) int { return p.X + p.Y }
+	farthestFrom := func(from image.Point) func(p image.Point) int {
+		return func(p image.Point) int {
+			d := p.Sub(from)
+			return d.X*d.X + d.Y*d.Y
+		}
+	}
+
+	var ok bool
+	switch {
+	case hasStart:
+		score := bottomRight
+		if opts.Convention == Farthest {
+			score = farthestFrom(start)
+		}
+		end, ok = bestOpening(openings, start, score)
+	case hasEnd:
+		score := topLeft
+		if opts.Convention == Farthest {
+			score = farthestFrom(end)
+		}
+		start, ok = bestOpening(openings, end, score)
+	case opts.Convention == Farthest:
+		bestDistance := -1
+		for i, a := range openings {
+			for _, b := range openings[i+1:] {
+				if d := farthestFrom(a)(b); d > bestDistance {
+					start, end, bestDistance = a, b, d
+				}
+			}
+		}
+		ok = bestDistance >= 0
+		if topLeft(end) > topLeft(start) {
+			start, end = end, start
+		}
+	default:
+		start, ok = bestOpening(openings, image.Pt(-1, -1), topLeft)
+		if ok {
+			end, ok = bestOpening(openings, start, bottomRight)
+		}
+	}
+	if !ok {
+		return image.Point{}, image.Point{}, fmt.Errorf("couldn't find a start and an end, the border has %d openings", len(openings))
+	}
+	return start, end, nil
+}
+
+// bestOpening is the opening other than exclude with the highest score
+func bestOpening(openings []image.Point, exclude image.Point, score func(p image.Point) int) (image.Point, bool) {
+	var best image.Point
+	found := false
+	for _, p := range openings {
+		if p == exclude {
+			continue
+		}
+		if !found || score(p) > score(best) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// Openings are the runs of open pixels on the border of the grid, each one
+// represented by its middle pixel, going clockwise from the top left corner
+func (g *Grid) Openings() []image.Point {
+	border := make([]image.Point, 0, 2*(g.Width+g.Height))
+	for x := 0; x < g.Width; x++ {
+		border = append(border, image.Pt(x, 0))
+	}
+	for y := 1; y < g.Height; y++ {
+		border = append(border, image.Pt(g.Width-1, y))
+	}
+	if g.Height > 1 {
+		for x := g.Width - 2; x >= 0; x-- {
+			border = append(border, image.Pt(x, g.Height-1))
+		}
+	}
+	if g.Width > 1 {
+		for y := g.Height - 2; y > 0; y-- {
+			border = append(border, image.Pt(0, y))
+		}
+	}
+
+	// start right after a wall so no run wraps around the top left corner
+	first := -1
+	for i, p := range border {
+		if !g.Open(p.X, p.Y) {
+			first = i
+			break
+		}
+	}
+	if first == -1 {
+		if len(border) == 0 {
+			return nil
+		}
+		return []image.Point{border[len(border)/2]}
+	}
+
+	// indices into border of the middle of every run
+	middles := make([]int, 0)
+	run := make([]int, 0)
+	for i := 1; i <= len(border); i++ {
+		j := (first + i) % len(border)
+		if g.Open(border[j].X, border[j].Y) {
+			run = append(run, j)
+			continue
+		}
+		if len(run) > 0 {
+			middles = append(middles, run[len(run)/2])
+			run = run[:0]
+		}
+	}
+	slices.Sort(middles)
+
+	openings := make([]image.Point, len(middles))
+	for i, j := range middles {
+		openings[i] = border[j]
+	}
+	return openings
+}
+
+// markers bigger than this across are walls drawn in a marker color, unless
+// the image is big enough for a marker to be bigger, see maxMarkerSpan
+const minMarkerSpan = 16
+
+// maxMarkerSpan is the most pixels a marker can span across in an image of
+// width x height, an eighth of its smaller side
+func maxMarkerSpan(width, height int) int {
+	return max(minMarkerSpan, min(width, height)/8)
+}
+
+// markerPixels has a bit for every pixel of an image matching a marker color
+type markerPixels struct {
+	width, height int
+	target        color.NRGBA64
+	match         Bitset
+}
+
+func newMarkerPixels(width, height int, marker color.Color) *markerPixels {
+	return &markerPixels{width: width, height: height, target: nrgba64(marker), match: NewBitset(width * height)}
+}
+
+// blob is the largest 4-connected group of matching pixels small enough to be
+// a marker. The matches are cleared as they're grouped.
+func (m *markerPixels) blob() []image.Point {
+	maxSpan := maxMarkerSpan(m.width, m.height)
+	var best, pixels, stack []image.Point
+	for i := 0; i < m.width*m.height; i++ {
+		if !m.match.Has(i) {
+			continue
+		}
+		m.match.Clear(i)
+		start := image.Pt(i%m.width, i/m.width)
+		stack = append(stack[:0], start)
+		pixels = pixels[:0]
+		bounds := image.Rectangle{Min: start, Max: start.Add(image.Pt(1, 1))}
+		tooBig := false
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			bounds = bounds.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+			tooBig = tooBig || bounds.Dx() > maxSpan || bounds.Dy() > maxSpan
+			if !tooBig {
+				pixels = append(pixels, p)
+			}
+			for _, d := range []image.Point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+				q := p.Add(d)
+				if q.X < 0 || q.Y < 0 || q.X >= m.width || q.Y >= m.height || !m.match.Has(q.Y*m.width+q.X) {
+					continue
+				}
+				m.match.Clear(q.Y*m.width + q.X)
+				stack = append(stack, q)
+			}
+		}
+		if !tooBig && len(pixels) > len(best) {
+			best = slices.Clone(pixels)
+		}
+	}
+	return best
+}
+
+// Markers are the pixels of an image matching the start and end marker
+// colors, collected before looking for the markers themselves
+type Markers struct {
+	width, height int
+	pixels        []*markerPixels
+}
+
+func newMarkers(width, height int, opts EndpointOptions) *Markers {
+	startMarker, endMarker := opts.StartMarker, opts.EndMarker
+	if startMarker == nil {
+		startMarker = DefaultStartMarker
+	}
+	if endMarker == nil {
+		endMarker = DefaultEndMarker
+	}
+	return &Markers{
+		width:  width,
+		height: height,
+		pixels: []*markerPixels{newMarkerPixels(width, height, startMarker), newMarkerPixels(width, height, endMarker)},
+	}
+}
+
+// MarkersFromReader collects the pixels matching the marker colors of opts in
+// the png read from r a row at a time, for images too big for
+// EndpointOptions.Image. Only the colors of opts are used.
+func MarkersFromReader(r io.Reader, opts EndpointOptions) (*Markers, error) {
+	var m *Markers
+	err := mazesPng.DecodeRows(r, func(y int, row mazesPng.RowView) error {
+		if m == nil {
+			m = newMarkers(row.Width(), row.Height(), opts)
+		}
+		for x := 0; x < row.Width(); x++ {
+			p, err := RowPixel(row, x)
+			if err != nil {
+				return fmt.Errorf("couldn't convert pixel (%d, %d): %w", x, y, err)
+			}
+			m.add(x, y, p.Color)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// markersFromImage collects the marker pixels of the first width x height
+// pixels of img
+func markersFromImage(img image.Image, width, height int, opts EndpointOptions) *Markers {
+	bounds := img.Bounds()
+	m := newMarkers(width, height, opts)
+	for y := 0; y < min(height, bounds.Dy()); y++ {
+		for x := 0; x < min(width, bounds.Dx()); x++ {
+			m.add(x, y, nrgba64(img.At(bounds.Min.X+x, bounds.Min.Y+y)))
+		}
+	}
+	return m
+}
+
+func (m *Markers) add(x, y int, c color.NRGBA64) {
+	for _, pixels := range m.pixels {
+		if markerMatches(c, pixels.target) {
+			pixels.match.Set(y*m.width + x)
+			return
+		}
+	}
+}
+
+// open looks for every marker, keeping the largest blob of pixels matching it
+// that's small enough to be a marker. It opens the pixels of those blobs in g
+// and returns, for every marker, the pixel of its blob closest to the blob's
+// center.
+func (m *Markers) open(g *Grid) ([]image.Point, []bool) {
+	points := make([]image.Point, len(m.pixels))
+	found := make([]bool, len(m.pixels))
+	for i, pixels := range m.pixels {
+		blob := pixels.blob()
+		if len(blob) == 0 {
+			continue
+		}
+		var sum image.Point
+		for _, p := range blob {
+			sum = sum.Add(p)
+			g.SetOpen(p.X, p.Y, true)
+		}
+		center := sum.Div(len(blob))
+		bestDistance := -1
+		for _, p := range blob {
+			d := p.Sub(center)
+			if distance := d.X*d.X + d.Y*d.Y; bestDistance == -1 || distance < bestDistance {
+				points[i], bestDistance = p, distance
+			}
+		}
+		found[i] = true
+	}
+	return points, found
+}
+
+func markerMatches(c, marker color.NRGBA64) bool {
+	near := func(a, b uint16) bool {
+		return absDiff(a, b) <= markerTolerance
+	}
+	return near(c.R, marker.R) && near(c.G, marker.G) && near(c.B, marker.B) && near(c.A, marker.A)
+}
+
+func absDiff(a, b uint16) uint16 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
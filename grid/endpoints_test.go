@@ -0,0 +1,232 @@
+package grid
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	mazesPng "mazes/png"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func gridFromStrings(rows ...string) *Grid {
+	g := New(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			g.SetOpen(x, y, c == '.')
+		}
+	}
+	return g
+}
+
+func TestFindEndpointsSampleMaze(t *testing.T) {
+	data, err := os.ReadFile("../mazediag21x21.png")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	g, err := FromReader(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	// the entrance and exit main used to hardcode
+	expectedStart, expectedEnd := image.Pt(0, 1), image.Pt(g.Width-1, g.Height-2)
+	for _, convention := range []Convention{TopLeftBottomRight, Farthest} {
+		start, end, err := FindEndpoints(g, EndpointOptions{Convention: convention})
+		if err != nil {
+			t.Fatalf("%s: expected no error, but got: %v", convention, err)
+		}
+		if start != expectedStart || end != expectedEnd {
+			t.Fatalf("%s: expected %v -> %v, but got: %v -> %v", convention, expectedStart, expectedEnd, start, end)
+		}
+	}
+}
+
+func TestOpenings(t *testing.T) {
+	g := gridFromStrings(
+		"...##",
+		"....#",
+		"#....",
+		".....",
+		"..###",
+	)
+	// the run on the top left wraps around the corner from (0, 1) to (2, 0),
+	// the one on the bottom left goes from (1, 4) up to (0, 3)
+	expected := []image.Point{{1, 0}, {4, 3}, {0, 4}}
+	if got := g.Openings(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected openings %v, but got: %v", expected, got)
+	}
+
+	if got := gridFromStrings("...", "...").Openings(); len(got) != 1 {
+		t.Fatalf("Expected a fully open border to be a single opening, but got: %v", got)
+	}
+	if _, _, err := FindEndpoints(gridFromStrings("#.#", "#.#", "###"), EndpointOptions{}); err == nil {
+		t.Fatalf("Expected an error with a single opening")
+	}
+}
+
+func TestFindEndpointsConventions(t *testing.T) {
+	g := gridFromStrings(
+		"##.######",
+		"#.......#",
+		"#.......#",
+		"#........",
+		"#.......#",
+		"####.####",
+	)
+	start, end, err := FindEndpoints(g, EndpointOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if start != image.Pt(2, 0) || end != image.Pt(8, 3) {
+		t.Fatalf("Expected (2,0) -> (8,3), but got: %v -> %v", start, end)
+	}
+
+	start, end, err = FindEndpoints(g, EndpointOptions{Convention: Farthest})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if start != image.Pt(2, 0) || end != image.Pt(8, 3) {
+		t.Fatalf("Expected (2,0) -> (8,3), but got: %v -> %v", start, end)
+	}
+}
+
+func TestFindEndpointsMarkers(t *testing.T) {
+	g := gridFromStrings(
+		"#.#######",
+		"#.......#",
+		"#.......#",
+		"#.......#",
+		"#######.#",
+	)
+	img := image.NewNRGBA(image.Rect(0, 0, g.Width, g.Height))
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if g.Open(x, y) {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	// a slightly off green blob and a red pixel over a wall
+	for _, p := range []image.Point{{3, 2}, {4, 2}, {5, 2}, {4, 1}, {4, 3}} {
+		img.Set(p.X, p.Y, color.NRGBA{R: 0x10, G: 0xF0, B: 0x08, A: 0xFF})
+	}
+	img.Set(8, 2, color.NRGBA{R: 0xFF, A: 0xFF})
+
+	start, end, err := FindEndpoints(g, EndpointOptions{Image: img})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if start != image.Pt(4, 2) || end != image.Pt(8, 2) {
+		t.Fatalf("Expected (4,2) -> (8,2), but got: %v -> %v", start, end)
+	}
+	if !g.Open(8, 2) {
+		t.Fatalf("Expected the end marker to be opened")
+	}
+
+	// only a start marker, the end comes from the border
+	img.Set(8, 2, color.Black)
+	g.SetOpen(8, 2, false)
+	start, end, err = FindEndpoints(g, EndpointOptions{Image: img})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if start != image.Pt(4, 2) || end != image.Pt(7, 4) {
+		t.Fatalf("Expected (4,2) -> (7,4), but got: %v -> %v", start, end)
+	}
+}
+
+func TestFindEndpointsIgnoresMarkerColoredWalls(t *testing.T) {
+	// a maze with red walls and a small red end marker
+	const size = 40
+	g := New(size, size)
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			open := x > 0 && x < size-1 && y%4 != 0
+			g.SetOpen(x, y, open || (x == 0 && y == 1) || (x == size-1 && y == size-2))
+			if g.Open(x, y) {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, red)
+			}
+		}
+	}
+	for _, p := range []image.Point{{20, 6}, {21, 6}} {
+		img.Set(p.X, p.Y, red)
+	}
+
+	_, end, err := FindEndpoints(g, EndpointOptions{Image: img})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if !end.In(image.Rect(20, 6, 22, 7)) {
+		t.Fatalf("Expected the end on the red marker, but got: %v", end)
+	}
+	for y := 0; y < size; y += 4 {
+		if g.Open(10, y) {
+			t.Fatalf("Expected the red wall at (10, %d) to stay closed", y)
+		}
+	}
+}
+
+func TestMarkersFromReader(t *testing.T) {
+	rows := []string{
+		"#.#######",
+		"#.......#",
+		"#.......#",
+		"#.......#",
+		"#######.#",
+	}
+	// the green blob is the start marker, the red pixel over a wall the end one
+	colors := map[image.Point]color.NRGBA{}
+	for _, p := range []image.Point{{3, 2}, {4, 2}, {5, 2}, {4, 1}, {4, 3}} {
+		colors[p] = color.NRGBA{R: 0x10, G: 0xF0, B: 0x08, A: 0xFF}
+	}
+	colors[image.Pt(8, 2)] = color.NRGBA{R: 0xFF, A: 0xFF}
+
+	g := gridFromStrings(rows...)
+	p := &mazesPng.Png{Width: g.Width, Height: g.Height, ColorType: mazesPng.ColorTypeTruecolor, BitDepth: 8}
+	p.Pixels = make([][]mazesPng.Pixel, p.Height)
+	for y := range p.Pixels {
+		p.Pixels[y] = make([]mazesPng.Pixel, p.Width)
+		for x := range p.Pixels[y] {
+			c, ok := colors[image.Pt(x, y)]
+			if !ok {
+				c = color.NRGBA{A: 0xFF}
+				if g.Open(x, y) {
+					c = color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+				}
+			}
+			p.Pixels[y][x] = &mazesPng.TruecolorPixel{Red: uint(c.R), Green: uint(c.G), Blue: uint(c.B), Alpha: 0xFF}
+		}
+	}
+	var buf bytes.Buffer
+	if err := mazesPng.Encode(&buf, p, mazesPng.EncodeOptions{}); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	markers, err := MarkersFromReader(&buf, EndpointOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	start, end, err := FindEndpoints(g, EndpointOptions{Markers: markers})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if start != image.Pt(4, 2) || end != image.Pt(8, 2) {
+		t.Fatalf("Expected (4,2) -> (8,2), but got: %v -> %v", start, end)
+	}
+	if !g.Open(8, 2) {
+		t.Fatalf("Expected the end marker to be opened")
+	}
+
+	_, _, err = FindEndpoints(New(g.Width+1, g.Height), EndpointOptions{Markers: markers})
+	if err == nil {
+		t.Fatalf("Expected an error for markers of another size")
+	}
+}
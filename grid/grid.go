@@ -0,0 +1,251 @@
+package grid
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	mazesPng "mazes/png"
+	"slices"
+)
+
+// Grid is a maze as a rectangle of open and wall pixels, a bit per pixel so a
+// 10001x10001 maze takes 12.5MB
+type Grid struct {
+	Width, Height int
+	open          Bitset
+}
+
+func New(width, height int) *Grid {
+	return &Grid{
+		Width:  width,
+		Height: height,
+		open:   NewBitset(width * height),
+	}
+}
+
+// Open reports whether the pixel at x, y can be walked on, everything outside of the grid is a wall
+func (g *Grid) Open(x, y int) bool {
+	if x < 0 || y < 0 || x >= g.Width || y >= g.Height {
+		return false
+	}
+	return g.open.Has(y*g.Width + x)
+}
+
+func (g *Grid) SetOpen(x, y int, open bool) {
+	if open {
+		g.open.Set(y*g.Width + x)
+	} else {
+		g.open.Clear(y*g.Width + x)
+	}
+}
+
+func (g *Grid) Bounds() image.Rectangle {
+	return image.Rect(0, 0, g.Width, g.Height)
+}
+
+// Index numbers the pixels row by row, for solvers keeping per pixel state in flat slices
+func (g *Grid) Index(x, y int) int {
+	return y*g.Width + x
+}
+
+// Pixel is what a Rule looks at to classify a pixel
+type Pixel struct {
+	Color color.NRGBA64
+	// Index is the palette index for palette images and -1 for every other one
+	Index int
+}
+
+// Rule decides whether a pixel is open or a wall
+type Rule func(p Pixel) bool
+
+// DefaultRule treats light pixels as open and dark or transparent ones as walls
+var DefaultRule = LuminanceAbove(0.5)
+
+// LuminanceAbove opens pixels whose luminance, between 0 and 1, is above
+// threshold. Translucent pixels are blended over black first.
+func LuminanceAbove(threshold float64) Rule {
+	return func(p Pixel) bool {
+		return luminance(p.Color) > threshold
+	}
+}
+
+// luminance is between 0 and 1, with c blended over black
+func luminance(c color.NRGBA64) float64 {
+	premultiplied := mazesPng.TruecolorPixel{
+		Red:   uint(c.R) * uint(c.A) / 0xFFFF,
+		Green: uint(c.G) * uint(c.A) / 0xFFFF,
+		Blue:  uint(c.B) * uint(c.A) / 0xFFFF,
+	}
+	return float64(mazesPng.Luminance(premultiplied)) / 0xFFFF
+}
+
+// MatchColor opens pixels that are exactly one of colors
+func MatchColor(colors ...color.Color) Rule {
+	matches := make([]color.NRGBA64, len(colors))
+	for i, c := range colors {
+		matches[i] = nrgba64(c)
+	}
+	return func(p Pixel) bool {
+		return slices.Contains(matches, p.Color)
+	}
+}
+
+// AlphaBelow opens pixels whose alpha, between 0 and 1, is below threshold,
+// for mazes drawn as opaque walls over a transparent background
+func AlphaBelow(threshold float64) Rule {
+	return func(p Pixel) bool {
+		return float64(p.Color.A)/0xFFFF < threshold
+	}
+}
+
+// PaletteIndices opens pixels of palette images using one of the indices,
+// pixels of other color types are always walls
+func PaletteIndices(indices ...int) Rule {
+	return func(p Pixel) bool {
+		return p.Index >= 0 && slices.Contains(indices, p.Index)
+	}
+}
+
+// Not opens the pixels rule considers walls and the other way around
+func Not(rule Rule) Rule {
+	return func(p Pixel) bool {
+		return !rule(p)
+	}
+}
+
+// nrgba64 converts c without going through premultiplied alpha when it can, so translucent colors stay exact
+func nrgba64(c color.Color) color.NRGBA64 {
+	switch c := c.(type) {
+	case color.NRGBA64:
+		return c
+	case color.NRGBA:
+		return color.NRGBA64{R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101, B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101}
+	}
+	return color.NRGBA64Model.Convert(c).(color.NRGBA64)
+}
+
+func truecolorToNRGBA64(c mazesPng.TruecolorPixel, bitDepth uint8) color.NRGBA64 {
+	if bitDepth != 16 {
+		c = mazesPng.To16Bit(c)
+	}
+	return color.NRGBA64{R: uint16(c.Red), G: uint16(c.Green), B: uint16(c.Blue), A: uint16(c.Alpha)}
+}
+
+// FromPng classifies every pixel of p with rule, nil uses DefaultRule
+func FromPng(p *mazesPng.Png, rule Rule) (*Grid, error) {
+	if rule == nil {
+		rule = DefaultRule
+	}
+	g := New(p.Width, p.Height)
+	for y, row := range p.Pixels {
+		for x, pixel := range row {
+			px, err := pngPixel(p, pixel)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't convert pixel (%d, %d): %w", x, y, err)
+			}
+			g.SetOpen(x, y, rule(px))
+		}
+	}
+	return g, nil
+}
+
+func pngPixel(p *mazesPng.Png, pixel mazesPng.Pixel) (Pixel, error) {
+	c, err := mazesPng.ToTruecolor(pixel, p.BitDepth, p.PlteEntries)
+	if err != nil {
+		return Pixel{}, err
+	}
+	index := -1
+	if palettePixel, ok := pixel.(*mazesPng.PalettePixel); ok {
+		index = int(palettePixel.Index)
+	}
+	px := Pixel{Color: truecolorToNRGBA64(c, p.BitDepth), Index: index}
+	if p.IsTransparent(pixel) {
+		px.Color.A = 0
+	}
+	return px, nil
+}
+
+// FromReader streams the png in r row by row, so only the grid itself has to
+// fit in memory. nil uses DefaultRule.
+func FromReader(r io.Reader, rule Rule) (*Grid, error) {
+	if rule == nil {
+		rule = DefaultRule
+	}
+	var g *Grid
+	err := mazesPng.DecodeRows(r, func(y int, row mazesPng.RowView) error {
+		if g == nil {
+			g = New(row.Width(), row.Height())
+		}
+		for x := 0; x < row.Width(); x++ {
+			p, err := RowPixel(row, x)
+			if err != nil {
+				return fmt.Errorf("couldn't convert pixel (%d, %d): %w", x, y, err)
+			}
+			g.SetOpen(x, y, rule(p))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// RowPixel is the pixel at x of a row of DecodeRows, the same as converting
+// row.Pixel(x) but without allocating
+func RowPixel(row mazesPng.RowView, x int) (Pixel, error) {
+	bitDepth := row.BitDepth()
+	scale := func(v uint) uint16 {
+		return uint16(mazesPng.ScaleSample(v, bitDepth, 16))
+	}
+	// pixels of the tRNS color of images without an alpha channel are transparent
+	opaque := uint16(0xFFFF)
+	if row.IsTransparent(x) {
+		opaque = 0
+	}
+	switch row.ColorType() {
+	case mazesPng.ColorTypeGrayscale:
+		v := scale(row.Sample(x, 0))
+		return Pixel{Color: color.NRGBA64{R: v, G: v, B: v, A: opaque}, Index: -1}, nil
+	case mazesPng.ColorTypeGrayscaleAlpha:
+		v := scale(row.Sample(x, 0))
+		return Pixel{Color: color.NRGBA64{R: v, G: v, B: v, A: scale(row.Sample(x, 1))}, Index: -1}, nil
+	case mazesPng.ColorTypeTruecolor:
+		return Pixel{Color: color.NRGBA64{R: scale(row.Sample(x, 0)), G: scale(row.Sample(x, 1)), B: scale(row.Sample(x, 2)), A: opaque}, Index: -1}, nil
+	case mazesPng.ColorTypeTruecolorAlpha:
+		return Pixel{Color: color.NRGBA64{R: scale(row.Sample(x, 0)), G: scale(row.Sample(x, 1)), B: scale(row.Sample(x, 2)), A: scale(row.Sample(x, 3))}, Index: -1}, nil
+	case mazesPng.ColorTypePalette:
+		index := row.Sample(x, 0)
+		palette := row.Palette()
+		if index >= uint(len(palette)) {
+			return Pixel{}, fmt.Errorf("palette index %d out of range, palette has %d entries", index, len(palette))
+		}
+		return Pixel{Color: truecolorToNRGBA64(palette[index], 8), Index: int(index)}, nil
+	}
+	return Pixel{}, fmt.Errorf("unknown color type %d", row.ColorType())
+}
+
+// FromImage classifies every pixel of img with rule, nil uses DefaultRule. The
+// grid starts at 0, 0 whatever the bounds of img are.
+func FromImage(img image.Image, rule Rule) *Grid {
+	if rule == nil {
+		rule = DefaultRule
+	}
+	bounds := img.Bounds()
+	g := New(bounds.Dx(), bounds.Dy())
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			g.SetOpen(x, y, rule(imagePixel(img, bounds.Min.X+x, bounds.Min.Y+y)))
+		}
+	}
+	return g
+}
+
+func imagePixel(img image.Image, x, y int) Pixel {
+	p := Pixel{Color: nrgba64(img.At(x, y)), Index: -1}
+	if paletted, ok := img.(*image.Paletted); ok {
+		p.Index = int(paletted.ColorIndexAt(x, y))
+	}
+	return p
+}
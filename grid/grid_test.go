@@ -0,0 +1,222 @@
+package grid
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	mazesPng "mazes/png"
+	"os"
+	"slices"
+	"testing"
+)
+
+// testPattern is open where x+y is a multiple of 3
+func testPattern(x, y int) bool {
+	return (x+y)%3 == 0
+}
+
+func testPng(colorType mazesPng.ColorType, bitDepth uint8) *mazesPng.Png {
+	p := &mazesPng.Png{Width: 7, Height: 5, ColorType: colorType, BitDepth: bitDepth}
+	maxValue := uint(1)<<bitDepth - 1
+	if colorType == mazesPng.ColorTypePalette {
+		p.PlteEntries = []mazesPng.TruecolorPixel{
+			{Red: 0x10, Green: 0x10, Blue: 0x40, Alpha: 0xFF},
+			{Red: 0xF0, Green: 0xE0, Blue: 0xF0, Alpha: 0xFF},
+		}
+	}
+	p.Pixels = make([][]mazesPng.Pixel, p.Height)
+	for y := range p.Pixels {
+		p.Pixels[y] = make([]mazesPng.Pixel, p.Width)
+		for x := range p.Pixels[y] {
+			v := uint(0)
+			if testPattern(x, y) {
+				v = maxValue
+			}
+			switch colorType {
+			case mazesPng.ColorTypeGrayscale:
+				p.Pixels[y][x] = &mazesPng.GreyscalePixel{Value: v}
+			case mazesPng.ColorTypeGrayscaleAlpha:
+				p.Pixels[y][x] = &mazesPng.GreyscaleAlphaPixel{Value: v, Alpha: maxValue}
+			case mazesPng.ColorTypeTruecolor, mazesPng.ColorTypeTruecolorAlpha:
+				p.Pixels[y][x] = &mazesPng.TruecolorPixel{Red: v, Green: v, Blue: v, Alpha: maxValue}
+			case mazesPng.ColorTypePalette:
+				p.Pixels[y][x] = &mazesPng.PalettePixel{Index: v & 1}
+			}
+		}
+	}
+	return p
+}
+
+func checkPattern(t *testing.T, g *Grid) {
+	t.Helper()
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			if g.Open(x, y) != testPattern(x, y) {
+				t.Fatalf("(%d, %d) expected open to be %v, but got: %v", x, y, testPattern(x, y), g.Open(x, y))
+			}
+		}
+	}
+}
+
+func TestFromPngEveryColorType(t *testing.T) {
+	tests := []struct {
+		colorType mazesPng.ColorType
+		bitDepth  uint8
+	}{
+		{mazesPng.ColorTypeGrayscale, 1},
+		{mazesPng.ColorTypeGrayscale, 4},
+		{mazesPng.ColorTypeGrayscale, 16},
+		{mazesPng.ColorTypeGrayscaleAlpha, 8},
+		{mazesPng.ColorTypeTruecolor, 8},
+		{mazesPng.ColorTypeTruecolorAlpha, 16},
+		{mazesPng.ColorTypePalette, 1},
+		{mazesPng.ColorTypePalette, 8},
+	}
+	for _, test := range tests {
+		p := testPng(test.colorType, test.bitDepth)
+		g, err := FromPng(p, nil)
+		if err != nil {
+			t.Fatalf("%s %d bits expected no error, but got: %v", test.colorType, test.bitDepth, err)
+		}
+		checkPattern(t, g)
+
+		var buf bytes.Buffer
+		if err := mazesPng.Encode(&buf, p, mazesPng.EncodeOptions{}); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		g, err = FromReader(&buf, nil)
+		if err != nil {
+			t.Fatalf("%s %d bits expected no error, but got: %v", test.colorType, test.bitDepth, err)
+		}
+		checkPattern(t, g)
+	}
+}
+
+func TestFromPngColorKey(t *testing.T) {
+	for _, colorType := range []mazesPng.ColorType{mazesPng.ColorTypeGrayscale, mazesPng.ColorTypeTruecolor} {
+		// the open pixels of the pattern are the ones with the tRNS color
+		p := testPng(colorType, 16)
+		p.Transparent = &mazesPng.TruecolorPixel{Red: 0xFFFF, Green: 0xFFFF, Blue: 0xFFFF}
+		g, err := FromPng(p, AlphaBelow(0.5))
+		if err != nil {
+			t.Fatalf("%s expected no error, but got: %v", colorType, err)
+		}
+		checkPattern(t, g)
+
+		var buf bytes.Buffer
+		if err := mazesPng.Encode(&buf, p, mazesPng.EncodeOptions{}); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		g, err = FromReader(&buf, AlphaBelow(0.5))
+		if err != nil {
+			t.Fatalf("%s expected no error, but got: %v", colorType, err)
+		}
+		checkPattern(t, g)
+	}
+}
+
+func TestFromReaderMatchesFromPng(t *testing.T) {
+	for _, name := range []string{"../mazediag21x21.png", "../mazediag201x201.png", "../palette.png"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		p, err := mazesPng.DecodePng(data)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		expected, err := FromPng(p, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		got, err := FromReader(bytes.NewReader(data), nil)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		if got.Width != expected.Width || got.Height != expected.Height || !slices.Equal(got.open, expected.open) {
+			t.Fatalf("%s: Expected FromReader to match FromPng", name)
+		}
+	}
+}
+
+func TestDefaultRuleOnSampleMaze(t *testing.T) {
+	data, err := os.ReadFile("../mazediag21x21.png")
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	p, err := mazesPng.DecodePng(data)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	g, err := FromPng(p, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	// the old classification in main, blue means open
+	for y, row := range p.Pixels {
+		for x, pixel := range row {
+			c, _ := mazesPng.ToTruecolor(pixel, p.BitDepth, p.PlteEntries)
+			if g.Open(x, y) != (c.Blue > 0) {
+				t.Fatalf("(%d, %d) %v expected open to be %v, but got: %v", x, y, c, c.Blue > 0, g.Open(x, y))
+			}
+		}
+	}
+}
+
+func TestFromImagePaletteIndices(t *testing.T) {
+	palette := color.Palette{color.Black, color.White, color.NRGBA{R: 0xFF, A: 0xFF}}
+	img := image.NewPaletted(image.Rect(3, 2, 8, 6), palette)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			img.SetColorIndex(x, y, uint8((x+y)%3))
+		}
+	}
+
+	g := FromImage(img, PaletteIndices(0, 2))
+	if g.Width != 5 || g.Height != 4 {
+		t.Fatalf("Expected a 5x4 grid, but got: %dx%d", g.Width, g.Height)
+	}
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			expected := (x+3+y+2)%3 != 1
+			if g.Open(x, y) != expected {
+				t.Fatalf("(%d, %d) expected open to be %v, but got: %v", x, y, expected, g.Open(x, y))
+			}
+		}
+	}
+
+	if g := FromImage(image.NewNRGBA(image.Rect(0, 0, 2, 2)), PaletteIndices(0)); g.Open(0, 0) {
+		t.Fatalf("Expected palette rules to not open pixels of other color types")
+	}
+}
+
+func TestRules(t *testing.T) {
+	translucent := color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0x78}
+	pixel := Pixel{Color: nrgba64(translucent), Index: -1}
+	tests := []struct {
+		name     string
+		rule     Rule
+		expected bool
+	}{
+		{"exact translucent color", MatchColor(color.White, translucent), true},
+		{"other color", MatchColor(color.NRGBA{R: 0x12, G: 0x34, B: 0x56, A: 0x79}), false},
+		{"alpha below", AlphaBelow(0.5), true},
+		{"alpha above", AlphaBelow(0.4), false},
+		{"dark", LuminanceAbove(0.1), false},
+		{"not dark", Not(LuminanceAbove(0.1)), true},
+		{"not a palette", PaletteIndices(-1), false},
+	}
+	for _, test := range tests {
+		if got := test.rule(pixel); got != test.expected {
+			t.Fatalf("%s: expected %v, but got: %v", test.name, test.expected, got)
+		}
+	}
+
+	// transparent white is blended over black so it's a wall
+	if LuminanceAbove(0.5)(Pixel{Color: color.NRGBA64{R: 0xFFFF, G: 0xFFFF, B: 0xFFFF}}) {
+		t.Fatalf("Expected transparent white to be a wall")
+	}
+	if g := New(2, 2); g.Open(-1, 0) || g.Open(0, 2) {
+		t.Fatalf("Expected pixels outside of the grid to be walls")
+	}
+}
@@ -0,0 +1,65 @@
+package grid
+
+import (
+	"io"
+	"math"
+
+	mazesPng "mazes/png"
+)
+
+// EncodeHeatmap writes g as a png colored by distances, indexed like g, from
+// blue for the nearest pixels to red for the farthest ones. Walls are black
+// and open pixels that can't be reached, with an infinite distance, gray. The
+// png is streamed row by row.
+func EncodeHeatmap(w io.Writer, g *Grid, distances []float64) error {
+	farthest := 0.0
+	for _, d := range distances {
+		if !math.IsInf(d, 1) {
+			farthest = math.Max(farthest, d)
+		}
+	}
+
+	header := mazesPng.IHDRData{
+		Width:     g.Width,
+		Height:    g.Height,
+		BitDepth:  8,
+		ColorType: mazesPng.ColorTypeTruecolor,
+	}
+	return mazesPng.EncodeRows(w, header, nil, mazesPng.EncodeOptions{}, func(y int, row mazesPng.RowBuffer) error {
+		for x := 0; x < row.Width(); x++ {
+			var c [3]uint
+			d := distances[g.Index(x, y)]
+			switch {
+			case !g.Open(x, y):
+			case math.IsInf(d, 1):
+				c = [3]uint{0x80, 0x80, 0x80}
+			default:
+				c = heatColor(d / math.Max(farthest, 1))
+			}
+			for i, v := range c {
+				row.SetSample(x, i, v)
+			}
+		}
+		return nil
+	})
+}
+
+// heatGradient goes from blue near the start through green and yellow to red far from it
+var heatGradient = [][3]float64{
+	{0x20, 0x40, 0xFF},
+	{0x20, 0xD0, 0x60},
+	{0xFF, 0xE0, 0x20},
+	{0xFF, 0x20, 0x20},
+}
+
+// heatColor is the color of heatGradient at t, between 0 and 1
+func heatColor(t float64) [3]uint {
+	t = math.Min(math.Max(t, 0), 1) * float64(len(heatGradient)-1)
+	i := min(int(t), len(heatGradient)-2)
+	frac := t - float64(i)
+	var c [3]uint
+	for j := range c {
+		c[j] = uint(math.Round(heatGradient[i][j] + (heatGradient[i+1][j]-heatGradient[i][j])*frac))
+	}
+	return c
+}
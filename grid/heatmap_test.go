@@ -0,0 +1,70 @@
+package grid
+
+import (
+	"bytes"
+	"math"
+	mazesPng "mazes/png"
+	"testing"
+)
+
+func TestEncodeHeatmap(t *testing.T) {
+	g := gridFromStrings(
+		"...#.",
+		"#....",
+	)
+	inf := math.Inf(1)
+	// the last pixel of the first row is walled off from the others
+	distances := []float64{
+		0, 1, 2, inf, inf,
+		inf, 4, 3, 4, 5,
+	}
+	var buf bytes.Buffer
+	if err := EncodeHeatmap(&buf, g, distances); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	p, err := mazesPng.DecodePng(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	nearest := [3]uint{0x20, 0x40, 0xFF}
+	farthest := [3]uint{0xFF, 0x20, 0x20}
+	gray := [3]uint{0x80, 0x80, 0x80}
+	black := [3]uint{}
+	for _, tc := range []struct {
+		x, y     int
+		expected [3]uint
+	}{
+		{0, 0, nearest},
+		{4, 1, farthest},
+		{4, 0, gray},
+		{3, 0, black},
+		{0, 1, black},
+		{2, 1, heatColor(0.6)},
+	} {
+		c, err := mazesPng.ToTruecolor(p.Pixels[tc.y][tc.x], p.BitDepth, p.PlteEntries)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		if got := [3]uint{c.Red, c.Green, c.Blue}; got != tc.expected {
+			t.Fatalf("Expected pixel (%d, %d) to be %v, but got: %v", tc.x, tc.y, tc.expected, got)
+		}
+	}
+}
+
+func TestHeatColor(t *testing.T) {
+	for _, tc := range []struct {
+		t        float64
+		expected [3]uint
+	}{
+		{0, [3]uint{0x20, 0x40, 0xFF}},
+		{-1, [3]uint{0x20, 0x40, 0xFF}},
+		{1.0 / 3, [3]uint{0x20, 0xD0, 0x60}},
+		{1, [3]uint{0xFF, 0x20, 0x20}},
+		{2, [3]uint{0xFF, 0x20, 0x20}},
+	} {
+		if got := heatColor(tc.t); got != tc.expected {
+			t.Fatalf("Expected heatColor(%v) to be %v, but got: %v", tc.t, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"mazes/grid"
+	"mazes/path"
+	"os"
+)
+
+// heatmap implements `mazes heatmap [flags] file`, coloring every open pixel
+// by how far it is from the start of the maze
+func heatmap(args []string) error {
+	flags := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	output := flags.String("o", "heatmap.png", "file the heatmap is written to")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: mazes heatmap [flags] file")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a single file")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	maze, err := grid.FromReader(bufio.NewReader(file), nil)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	start, _, err := grid.FindEndpoints(maze, grid.EndpointOptions{})
+	if err != nil {
+		return err
+	}
+	g := path.NewGridGraph(maze)
+	distances, err := path.DistanceField(context.Background(), g, g.Node(start))
+	if err != nil {
+		return err
+	}
+	return writeHeatmap(*output, maze, distances)
+}
+
+// writeHeatmap writes the heatmap of maze to filePath
+func writeHeatmap(filePath string, maze *grid.Grid, distances []float64) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	err = grid.EncodeHeatmap(w, maze, distances)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// the write already failed, that's the error worth returning
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -1,106 +1,423 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/veandco/go-sdl2/sdl"
 	"image"
 	"image/color"
-	"image/draw"
-	"image/png"
+	"io"
 	"log"
+	"math"
+	"mazes/grid"
 	"mazes/path"
 	mazesPng "mazes/png"
 	"os"
+	"runtime"
+	"strings"
 	"unsafe"
 )
 
+func init() {
+	// SDL has to be called from the main thread, and solvers run on another
+	// goroutine when animated
+	runtime.LockOSThread()
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "optimize" {
+		if err := optimize(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "heatmap" {
+		if err := heatmap(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	algo := flag.String("algo", "astar", "algorithm used to solve the maze, one of: "+strings.Join(path.Names(), ", "))
+	connectivity := flag.String("connectivity", "4", "neighbours a pixel connects to, one of: 4, 8, 8-no-corners")
+	heuristic := flag.String("heuristic", "", "distance A* estimates with, one of: manhattan, octile, chebyshev, euclidean, zero (default manhattan for 4-connected mazes, octile otherwise)")
+	terrain := flag.Bool("terrain", false, "solve the image as a cost map, nearly black pixels are walls and the others cost from 1 for white up to 10 for the darkest")
+	epsilon := flag.Float64("epsilon", 1, "weight of the heuristic, above 1 A* is faster but its paths can be up to epsilon times longer")
+	animate := flag.Int("animate", 0, "nodes expanded per frame of an animation of the search shown while solving, 0 only shows the solution")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: mazes [flags] [file]\n       mazes optimize [flags] file...\n       mazes heatmap [flags] file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	solver, ok := path.Lookup(*algo)
+	if !ok {
+		log.Fatalf("unknown algorithm %q, use one of: %s", *algo, strings.Join(path.Names(), ", "))
+	}
+
+	graph := path.GridGraph{}
+	switch *connectivity {
+	case "4":
+		graph.Connectivity = path.FourConnected
+	case "8":
+		graph.Connectivity = path.EightConnected
+	case "8-no-corners":
+		graph.Connectivity = path.EightConnectedNoCornerCutting
+	default:
+		log.Fatalf("unknown connectivity %q, use one of: 4, 8, 8-no-corners", *connectivity)
+	}
+	// the cost maps of -terrain are only walked 4-connected
+	if *terrain && graph.Connectivity != path.FourConnected {
+		log.Fatalf("-terrain only supports -connectivity 4, got: %v", graph.Connectivity)
+	}
+	if *heuristic != "" {
+		if graph.Distance, ok = path.LookupDistance(*heuristic); !ok {
+			log.Fatalf("unknown heuristic %q, use one of: manhattan, octile, chebyshev, euclidean, zero", *heuristic)
+		}
+	}
+	if math.IsNaN(*epsilon) || math.IsInf(*epsilon, 0) || *epsilon < 1 {
+		log.Fatalf("-epsilon has to be a finite number of at least 1, got: %v", *epsilon)
+	}
+	if *epsilon != 1 {
+		if graph.Distance == nil {
+			graph.Distance = path.DefaultDistance(graph.Connectivity)
+		}
+		graph.Distance = path.Weighted(graph.Distance, *epsilon)
+	}
+
 	filePath := "mazediag10001x10001.png"
 	//filePath := "mazediag201x201.png"
 	//filePath := "mazediag21x21.png"
 	//filePath := "palette.png"
 	//filePath := "pngtest.png"
-	data, err := os.ReadFile(filePath)
+	if flag.NArg() > 0 {
+		filePath = flag.Arg(0)
+	}
+	// the image is decoded a row at a time on every pass, so big mazes only
+	// cost their grid in memory
+	var maze *grid.Grid
+	var costs *grid.CostMap
+	err := readPng(filePath, func(r io.Reader) (err error) {
+		if *terrain {
+			costs, err = grid.CostsFromReader(r, grid.OpenOnly(grid.LuminanceAbove(terrainWallLuminance), grid.LuminanceCost(1, 10)))
+			if err == nil {
+				maze = costs.Grid()
+			}
+			return err
+		}
+		maze, err = grid.FromReader(r, grid.DefaultRule)
+		return err
+	})
 	if err != nil {
-		log.Fatalf("failed to read file: %v", err)
+		log.Fatal(err)
 	}
+	width := maze.Width
+	height := maze.Height
 
-	res, err := mazesPng.DecodePng(data)
+	var markers *grid.Markers
+	err = readPng(filePath, func(r io.Reader) (err error) {
+		markers, err = grid.MarkersFromReader(r, grid.EndpointOptions{})
+		return err
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	width := res.Width
-	height := res.Height
-
-	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
+	start, end, err := grid.FindEndpoints(maze, grid.EndpointOptions{Markers: markers})
+	if err != nil {
 		log.Fatal(err)
 	}
-	defer sdl.Quit()
+	// terrain costs more than a step per pixel
+	shortest := path.FewestEdges
+	if *terrain {
+		shortest = path.Cheapest
+	}
+	// a weighted heuristic gives up the guarantee of any solver using it
+	if path.GuaranteeOf(solver) < shortest || *epsilon > 1 {
+		log.Printf("%s isn't guaranteed to find the shortest path", *algo)
+	}
+	// images bigger than the window are shown as a downsampled overview,
+	// clicking on it zooms into a full resolution tile around the click
+	scale := (maxInt(width, height) + maxWindowSize - 1) / maxWindowSize
+	var window *sdl.Window
+	var anim *animation
+	if *animate > 0 {
+		view, err := decodeView(filePath, mazesPng.RegionOptions{Scale: scale}, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if window, err = openWindow(*algo, view.Rect.Size()); err != nil {
+			log.Fatal(err)
+		}
+		defer sdl.Quit()
+		defer window.Destroy()
+		anim = newAnimation(view, scale, *animate)
+	}
 
-	// Create a window
-	window, err := sdl.CreateWindow("Window", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, int32(width), int32(height), sdl.WINDOW_SHOWN)
+	solveMaze := func(ctx context.Context) ([]image.Point, error) {
+		if *terrain {
+			return solveTerrain(ctx, anim, solver, costs, graph.Distance, start, end)
+		}
+		return solve(ctx, anim, solver, graph, maze, start, end)
+	}
+	var solution []image.Point
+	if anim != nil {
+		solution, err = anim.play(window, solveMaze)
+	} else {
+		solution, err = solveMaze(context.Background())
+	}
+	if errors.Is(err, errWindowClosed) {
+		return
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer window.Destroy()
+	if err := writeOutput("output.png", filePath, solution); err != nil {
+		log.Fatal(err)
+	}
 
-	surface, err := window.GetSurface()
-	if err != nil {
+	var overview *image.RGBA
+	if anim != nil {
+		// the overview keeps what the search went through
+		overview = anim.view
+		for _, p := range solution {
+			overview.Set(p.X/scale, p.Y/scale, color.RGBA{R: 0xFF, G: 0x00, B: 0x00, A: 0xFF})
+		}
+	} else {
+		if overview, err = decodeView(filePath, mazesPng.RegionOptions{Scale: scale}, solution); err != nil {
+			log.Fatal(err)
+		}
+		if window, err = openWindow("Window", overview.Rect.Size()); err != nil {
+			log.Fatal(err)
+		}
+		defer sdl.Quit()
+		defer window.Destroy()
+	}
+
+	if err := showImage(window, overview); err != nil {
 		log.Fatal(err)
 	}
 
-	surface.FillRect(nil, sdl.MapRGB(surface.Format, 0, 0, 0)) // Black background
+	zoomed := false
+	// Handle events and keep window open
+	for {
+		// Poll events (close window)
+		event := sdl.WaitEvent()
+		switch e := event.(type) {
+		case *sdl.QuitEvent:
+			// Close the window when the user clicks the close button
+			return
+		case *sdl.MouseButtonEvent:
+			if e.State != sdl.PRESSED || scale == 1 {
+				continue
+			}
+			view := overview
+			if !zoomed {
+				tileW, tileH := overview.Rect.Dx(), overview.Rect.Dy()
+				x := minInt(maxInt(int(e.X)*scale-tileW/2, 0), width-tileW)
+				y := minInt(maxInt(int(e.Y)*scale-tileH/2, 0), height-tileH)
+				view, err = decodeView(filePath, mazesPng.RegionOptions{Rect: image.Rect(x, y, x+tileW, y+tileH)}, solution)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+			zoomed = !zoomed
+			if err := showImage(window, view); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+const maxWindowSize = 1000
+
+// openWindow initializes SDL and opens a window of the given size
+func openWindow(title string, size image.Point) (*sdl.Window, error) {
+	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
+		return nil, err
+	}
+	window, err := sdl.CreateWindow(title, sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, int32(size.X), int32(size.Y), sdl.WINDOW_SHOWN)
+	if err != nil {
+		sdl.Quit()
+		return nil, err
+	}
+	return window, nil
+}
 
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	maze := make([][]bool, res.Height)
+// solve finds the pixels of a path from start to end on maze, with the options
+// of graph. Mazes drawn as a lattice of cells are solved on their cells, so
+// thick walls and big cells don't multiply the work. Those are always solved
+// 4-connected, diagonal steps would cut across the wall posts between cells.
+func solve(ctx context.Context, anim *animation, solver path.Solver, graph path.GridGraph, maze *grid.Grid, start, end image.Point) ([]image.Point, error) {
+	lattice, err := grid.DetectLattice(maze)
+	if err != nil {
+		graph.Grid = maze
+		ctx = anim.context(ctx, func(n path.Node) image.Rectangle {
+			return pixelRect(graph.Point(n))
+		})
+		res, err := solver.Solve(ctx, graph, graph.Node(start), graph.Node(end))
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("solved in %v, %d pixels long, %d pixels expanded", res.Elapsed, len(res.Path), res.Expanded)
+		return toPoints(graph, res.Path), nil
+	}
+	if graph.Connectivity != path.FourConnected {
+		log.Printf("solving the maze as %dx%d cells, ignoring -connectivity %v", lattice.Cols, lattice.Rows, graph.Connectivity)
+		graph.Connectivity = path.FourConnected
+	}
+	cells := lattice.Cells(maze)
+	graph.Grid = cells.Grid
+	start, end = lattice.FromPixel(start), lattice.FromPixel(end)
+	ctx = anim.context(ctx, func(n path.Node) image.Rectangle {
+		return lattice.PixelRect(graph.Point(n))
+	})
+	res, err := solver.Solve(ctx, graph, graph.Node(start), graph.Node(end))
+	if err != nil {
+		return nil, err
+	}
+	// the lattice has a node for every cell and for every wall between two
+	log.Printf("solved %dx%d cells in %v, %d of the %d lattice nodes expanded", lattice.Cols, lattice.Rows, res.Elapsed, res.Expanded, cells.Grid.Width*cells.Grid.Height)
+	return cells.PixelPath(toPoints(graph, res.Path)), nil
+}
 
-	for y := 0; y < height; y++ {
-		maze[y] = make([]bool, res.Width)
+// pixels darker than this are walls with -terrain
+const terrainWallLuminance = 0.1
 
-		for x := 0; x < width; x++ {
-			pixel := res.Pixels[y][x]
-			if res.BitDepth > 8 {
-				convert16BitDepthPixelTo8Bit(pixel)
-			}
+// solveTerrain finds the cheapest path from start to end, stepping on a pixel
+// costs what costs says. A* estimates with distance, nil is Manhattan.
+func solveTerrain(ctx context.Context, anim *animation, solver path.Solver, costs *grid.CostMap, distance path.Distance, start, end image.Point) ([]image.Point, error) {
+	g, err := path.NewWeightedGrid(costs.Width, costs.Height, costs.Costs)
+	if err != nil {
+		return nil, err
+	}
+	g.Distance = distance
+	ctx = anim.context(ctx, func(n path.Node) image.Rectangle {
+		return pixelRect(g.Point(n))
+	})
+	res, err := solver.Solve(ctx, g, g.Node(start), g.Node(end))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("solved in %v, %d pixels long costing %.1f, %d pixels expanded", res.Elapsed, len(res.Path), res.Cost, res.Expanded)
+	points := make([]image.Point, len(res.Path))
+	for i, node := range res.Path {
+		points[i] = g.Point(node)
+	}
+	return points, nil
+}
 
-			switch p := pixel.(type) {
-			case *mazesPng.TruecolorPixel:
-				c := color.RGBA{
-					R: uint8(p.Red),
-					G: uint8(p.Green),
-					B: uint8(p.Blue),
-					A: uint8(p.Alpha),
+func toPoints(g path.GridGraph, nodes []path.Node) []image.Point {
+	points := make([]image.Point, len(nodes))
+	for i, node := range nodes {
+		points[i] = g.Point(node)
+	}
+	return points
+}
+
+// readPng opens the png at filePath for decode
+func readPng(filePath string, decode func(r io.Reader) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+	return decode(bufio.NewReader(file))
+}
+
+func writeOutput(filePath, sourcePath string, solution []image.Point) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	// the source is read again a row at a time, each one written as soon as
+	// the solution is drawn over it
+	var e *mazesPng.RowEncoder
+	var onPath grid.Bitset
+	var out []byte
+	err = readPng(sourcePath, func(r io.Reader) error {
+		return mazesPng.DecodeRows(r, func(y int, row mazesPng.RowView) error {
+			if e == nil {
+				header := mazesPng.IHDRData{
+					Width:     row.Width(),
+					Height:    row.Height(),
+					BitDepth:  8,
+					ColorType: mazesPng.ColorTypeTruecolor,
+				}
+				var err error
+				if e, err = mazesPng.NewRowEncoder(w, header, nil, mazesPng.EncodeOptions{}); err != nil {
+					return err
 				}
-				img.Set(x, y, c)
-				maze[y][x] = p.Blue > 0
-			case *mazesPng.GreyscalePixel:
-				c := color.RGBA{
-					R: uint8(p.Value),
-					G: uint8(p.Value),
-					B: uint8(p.Value),
-					A: uint8(0xFF),
+				onPath = grid.NewBitset(row.Width() * row.Height())
+				for _, p := range solution {
+					onPath.Set(p.Y*row.Width() + p.X)
 				}
-				img.Set(x, y, c)
-			case *mazesPng.PalettePixel:
-				truePixel := res.PlteEntries[p.Index]
-				c := color.RGBA{
-					R: uint8(truePixel.Red),
-					G: uint8(truePixel.Green),
-					B: uint8(truePixel.Blue),
-					A: uint8(truePixel.Alpha),
+				out = make([]byte, 3*row.Width())
+			}
+			for x := 0; x < row.Width(); x++ {
+				c := color.RGBA{R: 0xFF, A: 0xFF}
+				if !onPath.Has(y*row.Width() + x) {
+					p, err := grid.RowPixel(row, x)
+					if err != nil {
+						return fmt.Errorf("couldn't convert pixel (%d, %d): %w", x, y, err)
+					}
+					nrgba := color.NRGBA{R: uint8(p.Color.R >> 8), G: uint8(p.Color.G >> 8), B: uint8(p.Color.B >> 8), A: uint8(p.Color.A >> 8)}
+					// premultiplying blends it over black
+					c = color.RGBAModel.Convert(nrgba).(color.RGBA)
 				}
-				img.Set(x, y, c)
+				out[3*x], out[3*x+1], out[3*x+2] = c.R, c.G, c.B
 			}
+			return e.WriteRow(out)
+		})
+	})
+	if err == nil {
+		err = e.Close()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// the write already failed, that's the error worth returning
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// decodeView decodes the region of the image described by opts and draws the
+// part of the solution that falls inside of it
+func decodeView(filePath string, opts mazesPng.RegionOptions, solution []image.Point) (*image.RGBA, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	view, err := mazesPng.DecodeRegion(bufio.NewReader(file), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := maxInt(opts.Scale, 1)
+	for _, p := range solution {
+		if !opts.Rect.Empty() && !p.In(opts.Rect) {
+			continue
 		}
+		view.Set((p.X-opts.Rect.Min.X)/scale, (p.Y-opts.Rect.Min.Y)/scale, color.RGBA{R: 0xFF, G: 0x00, B: 0x00, A: 0xFF})
 	}
+	return view, nil
+}
 
-	endY := res.Height - 1
-	endX := res.Width - 1
-	solution := path.Astart(maze, 0, 1, endX, endY-1)
-	for _, node := range solution {
-		img.Set(node.X, node.Y, color.RGBA{R: 0xFF, G: 0x00, B: 0x00, A: 0xFF})
+func showImage(window *sdl.Window, img *image.RGBA) error {
+	surface, err := window.GetSurface()
+	if err != nil {
+		return err
 	}
 
+	surface.FillRect(nil, sdl.MapRGB(surface.Format, 0, 0, 0)) // Black background
+
 	// Create SDL Surface
 	overlaySurface, err := sdl.CreateRGBSurfaceWithFormatFrom(
 		unsafe.Pointer(&img.Pix[0]), // Convert to pointer
@@ -111,53 +428,33 @@ func main() {
 		sdl.PIXELFORMAT_ABGR8888, // Format that supports transparency
 	)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer overlaySurface.Free()
+
+	// Enable per-pixel alpha blending
+	overlaySurface.SetBlendMode(sdl.BLENDMODE_BLEND)
 
 	overlayRect := sdl.Rect{X: 0, Y: 0, W: overlaySurface.W, H: overlaySurface.H}
 	// Blit (blend) the overlay image onto the background surface
 	err = overlaySurface.Blit(nil, surface, &overlayRect)
 	if err != nil {
-		fmt.Println("Failed to blit overlay image:", err)
-		return
+		return fmt.Errorf("failed to blit overlay image: %w", err)
 	}
-	bg := image.NewRGBA(image.Rect(0, 0, width, height))
 
-	// Fill the background with a solid color (black)
-	draw.Draw(bg, bg.Bounds(), &image.Uniform{color.RGBA{0, 0, 0, 255}}, image.Point{}, draw.Src)
-	draw.Draw(bg, img.Bounds(), img, image.Point{}, draw.Over)
-
-	// Enable per-pixel alpha blending
-	overlaySurface.SetBlendMode(sdl.BLENDMODE_BLEND)
-
-	window.UpdateSurface()
-
-	file, _ := os.Create("output.png")
-	defer file.Close()
-	png.Encode(file, bg)
+	return window.UpdateSurface()
+}
 
-	// Handle events and keep window open
-	for {
-		// Poll events (close window)
-		event := sdl.WaitEvent()
-		switch event.(type) {
-		case *sdl.QuitEvent:
-			// Close the window when the user clicks the close button
-			return
-		}
+func minInt(a, b int) int {
+	if a < b {
+		return a
 	}
+	return b
 }
 
-func convert16BitDepthPixelTo8Bit(pixel mazesPng.Pixel) mazesPng.Pixel {
-	switch p := pixel.(type) {
-	case *mazesPng.TruecolorPixel:
-		p.Red = p.Red >> 8
-		p.Green = p.Green >> 8
-		p.Blue = p.Blue >> 8
-		p.Alpha = p.Alpha >> 8
-	case *mazesPng.GreyscalePixel:
-		p.Value = p.Value >> 8
+func maxInt(a, b int) int {
+	if a > b {
+		return a
 	}
-
-	return pixel
+	return b
 }
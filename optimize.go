@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	mazesPng "mazes/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// optimize implements `mazes optimize [flags] file...`, losslessly recompressing
+// every file in place, files that can't be made smaller are left untouched
+func optimize(args []string) error {
+	flags := flag.NewFlagSet("optimize", flag.ExitOnError)
+	keep := flags.String("keep", "", "comma separated ancillary chunks to keep, e.g. tEXt,pHYs, every other one is stripped")
+	levels := flags.String("levels", "", "comma separated zlib compression levels to try, from 1 to 9, defaults to 9")
+	output := flags.String("o", "", "write the optimized image to this file instead of overwriting the input")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: mazes optimize [flags] file...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	files := flags.Args()
+	if len(files) == 0 {
+		flags.Usage()
+		return errors.New("no files to optimize")
+	}
+	if *output != "" && len(files) > 1 {
+		return errors.New("-o can only be used with a single file")
+	}
+
+	opts := mazesPng.OptimizeOptions{}
+	for _, chunkType := range strings.Split(*keep, ",") {
+		if chunkType != "" {
+			opts.KeepChunks = append(opts.KeepChunks, mazesPng.ChunkType(chunkType))
+		}
+	}
+	for _, level := range strings.Split(*levels, ",") {
+		if level == "" {
+			continue
+		}
+		l, err := strconv.Atoi(level)
+		if err != nil || l < 1 || l > 9 {
+			return fmt.Errorf("invalid compression level: %s", level)
+		}
+		opts.CompressionLevels = append(opts.CompressionLevels, mazesPng.CompressionLevel(l))
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		res, err := mazesPng.Optimize(data, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for _, chunkType := range res.DroppedChunks {
+			fmt.Printf("%s: dropped %s, it doesn't apply to the new color type\n", file, chunkType)
+		}
+
+		dst := file
+		if *output != "" {
+			dst = *output
+		}
+		if len(res.Data) >= len(data) && dst == file {
+			fmt.Printf("%s: already optimal at %d bytes\n", file, len(data))
+			continue
+		}
+		if err := writeFileAtomic(dst, res.Data); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d -> %d bytes (%s, %d bit, filters %v, compression %s)\n", dst, len(data), len(res.Data), res.ColorType, res.BitDepth, res.Filters, res.CompressionLevel)
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file next to path and renames it over
+// path, so the original is never left half written
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mazes-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
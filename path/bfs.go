@@ -0,0 +1,167 @@
+package path
+
+import (
+	"context"
+	"mazes/grid"
+	"slices"
+	"time"
+)
+
+// BFS finds the path with the fewest edges, which is the shortest one when
+// every edge costs the same, like on a pixel grid. It ignores the costs of
+// weighted graphs.
+var BFS Solver = guaranteed{bfs, FewestEdges}
+
+func init() {
+	Register("bfs", BFS)
+}
+
+// nodeQueue is a FIFO ring buffer, it doubles when it's full
+type nodeQueue struct {
+	nodes      []Node
+	head, size int
+}
+
+func newNodeQueue(capacity int) *nodeQueue {
+	size := 1
+	for size < capacity {
+		size *= 2
+	}
+	return &nodeQueue{nodes: make([]Node, size)}
+}
+
+func (q *nodeQueue) Len() int {
+	return q.size
+}
+
+func (q *nodeQueue) push(n Node) {
+	if q.size == len(q.nodes) {
+		nodes := make([]Node, 2*len(q.nodes))
+		copied := copy(nodes, q.nodes[q.head:])
+		copy(nodes[copied:], q.nodes[:q.head])
+		q.nodes, q.head = nodes, 0
+	}
+	q.nodes[(q.head+q.size)&(len(q.nodes)-1)] = n
+	q.size++
+}
+
+func (q *nodeQueue) pop() Node {
+	n := q.nodes[q.head]
+	q.head = (q.head + 1) & (len(q.nodes) - 1)
+	q.size--
+	return n
+}
+
+// bfs keeps a bit per node for the ones already queued and the index of the
+// edge going back to their parent, that's 3 bits per pixel of a grid
+func bfs(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	if gg, ok := g.(GridGraph); ok && gg.Connectivity == FourConnected {
+		return bfsGrid(ctx, gg, start, goal, began)
+	}
+
+	trace := newTracer(ctx)
+	seen := grid.NewBitset(g.Len())
+	via := newParents(g.Len(), g.MaxDegree())
+	queue := newNodeQueue(1024)
+	seen.Set(int(start))
+	queue.push(start)
+
+	expanded := 0
+	edges := make([]Edge, 0, g.MaxDegree())
+	buf := make([]Edge, 0, g.MaxDegree())
+	for queue.Len() > 0 && !seen.Has(int(goal)) {
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		curr := queue.pop()
+		expanded++
+		edges = g.Neighbors(curr, edges)
+		for _, edge := range edges {
+			if seen.Has(int(edge.To)) {
+				continue
+			}
+			var err error
+			if buf, err = via.setParent(g, curr, edge.To, buf); err != nil {
+				return Result{}, err
+			}
+			seen.Set(int(edge.To))
+			queue.push(edge.To)
+			trace.push(edge.To)
+		}
+		trace.expand(curr, queue.Len())
+	}
+	if !seen.Has(int(goal)) {
+		return Result{}, ErrNoPath
+	}
+	path := via.walkBack(g, start, goal)
+	return Result{Path: path, Cost: pathCost(g, path), Expanded: expanded, Elapsed: time.Since(began)}, nil
+}
+
+// bfsGrid is bfs without going through Neighbors, the parent of a pixel is
+// stored as the step from it to its parent, so 2 bits per pixel
+func bfsGrid(ctx context.Context, g GridGraph, start, goal Node, began time.Time) (Result, error) {
+	width := g.Grid.Width
+	trace := newTracer(ctx)
+	seen := grid.NewBitset(g.Len())
+	via := newParents(g.Len(), len(steps))
+	queue := newNodeQueue(1024)
+	seen.Set(int(start))
+	queue.push(start)
+
+	expanded := 0
+	for queue.Len() > 0 && !seen.Has(int(goal)) {
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		curr := queue.pop()
+		expanded++
+		x, y := int(curr)%width, int(curr)/width
+		for i, step := range steps {
+			next := curr + Node(step.Y*width+step.X)
+			if !g.Grid.Open(x+step.X, y+step.Y) || seen.Has(int(next)) {
+				continue
+			}
+			// steps are listed so that the opposite of step i is step 3-i
+			via.set(next, len(steps)-1-i)
+			seen.Set(int(next))
+			queue.push(next)
+			trace.push(next)
+		}
+		trace.expand(curr, queue.Len())
+	}
+	if !seen.Has(int(goal)) {
+		return Result{}, ErrNoPath
+	}
+
+	path := make([]Node, 0)
+	for curr := goal; ; {
+		path = append(path, curr)
+		if curr == start {
+			break
+		}
+		step := steps[via.get(curr)]
+		curr += Node(step.Y*width + step.X)
+	}
+	slices.Reverse(path)
+	return Result{Path: path, Cost: float64(len(path) - 1), Expanded: expanded, Elapsed: time.Since(began)}, nil
+}
+
+// pathCost adds up the costs of the edges along path
+func pathCost(g Graph, path []Node) float64 {
+	cost := 0.0
+	edges := make([]Edge, 0, g.MaxDegree())
+	for i := 1; i < len(path); i++ {
+		edges = g.Neighbors(path[i-1], edges)
+		for _, edge := range edges {
+			if edge.To == path[i] {
+				cost += edge.Cost
+				break
+			}
+		}
+	}
+	return cost
+}
@@ -0,0 +1,262 @@
+package path
+
+import (
+	"context"
+	"math"
+	"mazes/grid"
+	"slices"
+	"time"
+)
+
+var (
+	// BidirectionalBFS runs BFS from both ends a level at a time, growing the
+	// smaller frontier, until they meet. Like BFS it finds the path with the
+	// fewest edges.
+	BidirectionalBFS Solver = guaranteed{bidirectionalBFS, FewestEdges}
+	// BidirectionalDijkstra searches from both ends, growing the smaller
+	// frontier, and stops once no path through the unexplored nodes can beat
+	// the best one found
+	BidirectionalDijkstra Solver = guaranteed{func(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+		return bidirectionalDijkstra(ctx, false, g, start, goal)
+	}, Cheapest}
+	// BidirectionalAStar is BidirectionalDijkstra guided by the estimates of
+	// graphs that are an Estimator
+	BidirectionalAStar Solver = guaranteed{func(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+		return bidirectionalDijkstra(ctx, true, g, start, goal)
+	}, Cheapest}
+)
+
+func init() {
+	Register("bidirectional-bfs", BidirectionalBFS)
+	Register("bidirectional-dijkstra", BidirectionalDijkstra)
+	Register("bidirectional-astar", BidirectionalAStar)
+}
+
+// joinPaths joins the path from start to meet found going forward with the
+// one from goal to meet found going backward
+func joinPaths(g Graph, forward, backward parents, start, goal, meet Node) []Node {
+	path := forward.walkBack(g, start, meet)
+	back := backward.walkBack(g, goal, meet)
+	slices.Reverse(back)
+	return append(path, back[1:]...)
+}
+
+type bfsSide struct {
+	seen  grid.Bitset
+	via   parents
+	queue *nodeQueue
+}
+
+func newBFSSide(g Graph, from Node) *bfsSide {
+	side := &bfsSide{seen: grid.NewBitset(g.Len()), via: newParents(g.Len(), g.MaxDegree()), queue: newNodeQueue(1024)}
+	side.seen.Set(int(from))
+	side.queue.push(from)
+	return side
+}
+
+// bidirectionalBFS stops as soon as a side reaches a node the other one has
+// seen. Since sides grow a whole level at a time, the other side has seen
+// that node at its last level, or the sides would have met earlier, so every
+// path meeting at this level is as short. Which side grows doesn't matter for
+// that, growing the smaller frontier keeps both from spreading further than
+// they need.
+func bidirectionalBFS(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	if start == goal {
+		return Result{Path: []Node{start}, Elapsed: time.Since(began)}, nil
+	}
+
+	trace := newTracer(ctx)
+	sides := [2]*bfsSide{newBFSSide(g, start), newBFSSide(g, goal)}
+	meet := Node(-1)
+	expanded := 0
+	edges := make([]Edge, 0, g.MaxDegree())
+	buf := make([]Edge, 0, g.MaxDegree())
+	for meet < 0 {
+		turn := smallerSide(sides[0].queue.Len(), sides[1].queue.Len())
+		side, other := sides[turn], sides[1-turn]
+		if side.queue.Len() == 0 {
+			return Result{}, ErrNoPath
+		}
+		for level := side.queue.Len(); level > 0 && meet < 0; level-- {
+			if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			curr := side.queue.pop()
+			expanded++
+			edges = g.Neighbors(curr, edges)
+			for _, edge := range edges {
+				if side.seen.Has(int(edge.To)) {
+					continue
+				}
+				var err error
+				if buf, err = side.via.setParent(g, curr, edge.To, buf); err != nil {
+					return Result{}, err
+				}
+				side.seen.Set(int(edge.To))
+				side.queue.push(edge.To)
+				trace.push(edge.To)
+				if other.seen.Has(int(edge.To)) {
+					meet = edge.To
+					break
+				}
+			}
+			trace.expand(curr, side.queue.Len()+other.queue.Len())
+		}
+	}
+	path := joinPaths(g, sides[0].via, sides[1].via, start, goal, meet)
+	return Result{Path: path, Cost: pathCost(g, path), Expanded: expanded, Elapsed: time.Since(began)}, nil
+}
+
+type dijkstraSide struct {
+	closed grid.Bitset
+	via    parents
+	// reached has every node the side got to, closed or still in the queue,
+	// with how far it is for where the sides meet
+	reached map[Node]*DNode
+	queue   nodeHeap
+	queued  int
+}
+
+// distanceTo is how far n is from where the side started, +Inf if the side
+// didn't get to it
+func (s *dijkstraSide) distanceTo(n Node) float64 {
+	if node, ok := s.reached[n]; ok {
+		return node.distance
+	}
+	return math.Inf(1)
+}
+
+// bidirectionalDijkstra runs Dijkstra forward from start and backward from
+// goal on edges taken the other way, and keeps the shortest path through a
+// node both reached. It stops when the tops of both queues add up to at least
+// that path, no path left can be shorter, whichever side grew. Going backward
+// an edge can cost something else the other way, unless g is Symmetric the
+// edges of the node reached are listed to find it.
+//
+// With astar the sides are guided by the average of the estimates to goal and
+// from start, p(n) = (Estimate(n, goal) - Estimate(start, n)) / 2, added to
+// the distance going forward and taken from it going backward. The two sides
+// then see the same reduced edge costs, which keeps the stopping rule above
+// right, unlike with a separate estimate for each side.
+func bidirectionalDijkstra(ctx context.Context, astar bool, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	if start == goal {
+		return Result{Path: []Node{start}, Elapsed: time.Since(began)}, nil
+	}
+	estimator, _ := g.(Estimator)
+	if !astar {
+		estimator = nil
+	}
+	symmetric, _ := g.(Symmetric)
+	sameBothWays := symmetric != nil && symmetric.Symmetric()
+	potential := func(n Node) float64 {
+		if estimator == nil {
+			return 0
+		}
+		return (estimator.Estimate(n, goal) - estimator.Estimate(start, n)) / 2
+	}
+
+	trace := newTracer(ctx)
+	var sides [2]*dijkstraSide
+	for i, from := range []Node{start, goal} {
+		sides[i] = &dijkstraSide{
+			closed:  grid.NewBitset(g.Len()),
+			via:     newParents(g.Len(), g.MaxDegree()),
+			reached: make(map[Node]*DNode),
+		}
+		node := &DNode{Node: from, distanceRemaining: potential(from)}
+		if i == 1 {
+			node.distanceRemaining = -node.distanceRemaining
+		}
+		sides[i].reached[from] = node
+		sides[i].queue.push(node)
+	}
+
+	best, meet := math.Inf(1), Node(-1)
+	expanded := 0
+	edges := make([]Edge, 0, g.MaxDegree())
+	buf := make([]Edge, 0, g.MaxDegree())
+	for sides[0].queue.Len() > 0 && sides[1].queue.Len() > 0 {
+		if sides[0].queue[0].priority()+sides[1].queue[0].priority() >= best {
+			break
+		}
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		turn := smallerSide(sides[0].queue.Len(), sides[1].queue.Len())
+		side, other := sides[turn], sides[1-turn]
+		top := side.queue.pop()
+		side.closed.Set(int(top.Node))
+		expanded++
+
+		edges = g.Neighbors(top.Node, edges)
+		for _, edge := range edges {
+			if side.closed.Has(int(edge.To)) {
+				continue
+			}
+			// going backward the step is taken from edge.To to top, the edge
+			// back is only looked up when its cost or the parent is needed
+			cost, back := edge.Cost, -1
+			var err error
+			if turn == 1 && !sameBothWays {
+				if buf, back, err = reverseEdge(g, top.Node, edge.To, buf); err != nil {
+					return Result{}, err
+				}
+				cost = buf[back].Cost
+			}
+			newDistance := top.distance + cost
+
+			child, queued := side.reached[edge.To]
+			if queued && newDistance >= child.distance {
+				continue
+			}
+			if back < 0 {
+				if buf, back, err = reverseEdge(g, top.Node, edge.To, buf); err != nil {
+					return Result{}, err
+				}
+			}
+			side.via.set(edge.To, back)
+			trace.push(edge.To)
+			if queued {
+				child.distance = newDistance
+				side.queue.decreased(child)
+			} else {
+				side.queued++
+				child = &DNode{Node: edge.To, distance: newDistance, distanceRemaining: potential(edge.To), order: side.queued}
+				if turn == 1 {
+					child.distanceRemaining = -child.distanceRemaining
+				}
+				side.reached[edge.To] = child
+				side.queue.push(child)
+			}
+			if through := newDistance + other.distanceTo(edge.To); through < best {
+				best, meet = through, edge.To
+			}
+		}
+		trace.expand(top.Node, side.queue.Len()+other.queue.Len())
+	}
+	if meet < 0 {
+		return Result{}, ErrNoPath
+	}
+	return Result{
+		Path:     joinPaths(g, sides[0].via, sides[1].via, start, goal, meet),
+		Cost:     best,
+		Expanded: expanded,
+		Elapsed:  time.Since(began),
+	}, nil
+}
+
+// smallerSide is the side with the shorter queue, ties go to the one from start
+func smallerSide(forward, backward int) int {
+	if backward < forward {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package path
+
+import (
+	"context"
+	"mazes/grid"
+	"time"
+)
+
+var (
+	Dijkstra Solver = guaranteed{func(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+		return dijkstraOrAStar(ctx, false, g, start, goal)
+	}, Cheapest}
+	// AStar is Dijkstra guided by the estimates of graphs that are an
+	// Estimator, it's the same as Dijkstra on other graphs
+	AStar Solver = guaranteed{func(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+		return dijkstraOrAStar(ctx, true, g, start, goal)
+	}, Cheapest}
+)
+
+func init() {
+	Register("dijkstra", Dijkstra)
+	Register("astar", AStar)
+}
+
+type DNode struct {
+	Node
+
+	distance          float64
+	distanceRemaining float64
+	// index is the position of the node in the queue and order the number of
+	// nodes queued before it
+	index, order int
+}
+
+func (n *DNode) priority() float64 {
+	return n.distance + n.distanceRemaining
+}
+
+// dijkstraOrAStar keeps a bit per node for the closed ones and the index of
+// the edge that reached them, only the nodes still in the queue are allocated.
+// Estimates follow the rules of Estimator, so a closed node already has its
+// shortest distance.
+func dijkstraOrAStar(ctx context.Context, astar bool, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	estimator, _ := g.(Estimator)
+	if !astar {
+		estimator = nil
+	}
+
+	trace := newTracer(ctx)
+	closed := grid.NewBitset(g.Len())
+	via := newParents(g.Len(), g.MaxDegree())
+	open := make(map[Node]*DNode)
+
+	pqueue := make(nodeHeap, 0)
+	queued := 0
+	startNode := &DNode{Node: start, distance: 0}
+	open[start] = startNode
+	pqueue.push(startNode)
+
+	expanded := 0
+	edges := make([]Edge, 0, g.MaxDegree())
+	buf := make([]Edge, 0, g.MaxDegree())
+	for pqueue.Len() > 0 {
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		top := pqueue.pop()
+		delete(open, top.Node)
+		closed.Set(int(top.Node))
+		if top.Node == goal {
+			return Result{
+				Path:     via.walkBack(g, start, goal),
+				Cost:     top.distance,
+				Expanded: expanded,
+				Elapsed:  time.Since(began),
+			}, nil
+		}
+		expanded++
+
+		edges = g.Neighbors(top.Node, edges)
+		for _, edge := range edges {
+			if closed.Has(int(edge.To)) {
+				continue
+			}
+			newDistance := top.distance + edge.Cost
+
+			childNode, ok := open[edge.To]
+			if ok && newDistance >= childNode.distance {
+				continue
+			}
+			var err error
+			if buf, err = via.setParent(g, top.Node, edge.To, buf); err != nil {
+				return Result{}, err
+			}
+			trace.push(edge.To)
+			if ok {
+				childNode.distance = newDistance
+				pqueue.decreased(childNode)
+				continue
+			}
+
+			queued++
+			childNode = &DNode{Node: edge.To, distance: newDistance, order: queued}
+			if estimator != nil {
+				childNode.distanceRemaining = estimator.Estimate(edge.To, goal)
+			}
+			open[edge.To] = childNode
+			pqueue.push(childNode)
+		}
+		trace.expand(top.Node, pqueue.Len())
+	}
+	return Result{}, ErrNoPath
+}
@@ -0,0 +1,93 @@
+package path
+
+import (
+	"context"
+	"math"
+	"mazes/grid"
+)
+
+// DistanceField is the cost of the shortest path from source to every node of
+// g, +Inf for the ones that can't be reached. It's 8 bytes per node.
+func DistanceField(ctx context.Context, g Graph, source Node) ([]float64, error) {
+	if err := checkEndpoints(g, source, source); err != nil {
+		return nil, err
+	}
+	distances := make([]float64, g.Len())
+	for i := range distances {
+		distances[i] = math.Inf(1)
+	}
+	distances[source] = 0
+	if gg, ok := g.(GridGraph); ok && gg.Connectivity == FourConnected {
+		return distances, bfsField(ctx, g, source, distances)
+	}
+	return distances, dijkstraField(ctx, g, source, distances)
+}
+
+// bfsField fills distances for graphs whose edges all cost 1
+func bfsField(ctx context.Context, g Graph, source Node, distances []float64) error {
+	trace := newTracer(ctx)
+	queue := newNodeQueue(1024)
+	queue.push(source)
+	edges := make([]Edge, 0, g.MaxDegree())
+	for expanded := 0; queue.Len() > 0; expanded++ {
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		curr := queue.pop()
+		edges = g.Neighbors(curr, edges)
+		for _, edge := range edges {
+			if math.IsInf(distances[edge.To], 1) {
+				distances[edge.To] = distances[curr] + 1
+				queue.push(edge.To)
+				trace.push(edge.To)
+			}
+		}
+		trace.expand(curr, queue.Len())
+	}
+	return nil
+}
+
+// dijkstraField is dijkstraOrAStar without a goal to stop at
+func dijkstraField(ctx context.Context, g Graph, source Node, distances []float64) error {
+	trace := newTracer(ctx)
+	closed := grid.NewBitset(g.Len())
+	open := make(map[Node]*DNode)
+	pqueue := make(nodeHeap, 0)
+	queued := 0
+	open[source] = &DNode{Node: source}
+	pqueue.push(open[source])
+
+	edges := make([]Edge, 0, g.MaxDegree())
+	for expanded := 0; pqueue.Len() > 0; expanded++ {
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		top := pqueue.pop()
+		delete(open, top.Node)
+		closed.Set(int(top.Node))
+		distances[top.Node] = top.distance
+
+		edges = g.Neighbors(top.Node, edges)
+		for _, edge := range edges {
+			if closed.Has(int(edge.To)) {
+				continue
+			}
+			newDistance := top.distance + edge.Cost
+			if childNode, ok := open[edge.To]; ok {
+				if newDistance < childNode.distance {
+					childNode.distance = newDistance
+					pqueue.decreased(childNode)
+					trace.push(edge.To)
+				}
+				continue
+			}
+			queued++
+			childNode := &DNode{Node: edge.To, distance: newDistance, order: queued}
+			open[edge.To] = childNode
+			pqueue.push(childNode)
+			trace.push(edge.To)
+		}
+		trace.expand(top.Node, pqueue.Len())
+	}
+	return nil
+}
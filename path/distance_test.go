@@ -0,0 +1,56 @@
+package path
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistanceField(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		cols, rows := 1+r.Intn(20), 1+r.Intn(20)
+		maze := randomBraidedMaze(r, cols, rows, r.Float64()*0.5)
+		// a wall in the middle of the maze cuts some of it off
+		for y := 0; y < maze.Height; y++ {
+			maze.SetOpen(maze.Width/2, y, maze.Open(maze.Width/2, y) && r.Float64() < 0.8)
+		}
+		start := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+
+		for _, connectivity := range []Connectivity{FourConnected, EightConnected} {
+			g := GridGraph{Grid: maze, Connectivity: connectivity}
+			distances, err := DistanceField(context.Background(), g, g.Node(start))
+			if err != nil {
+				t.Fatalf("Expected no error, but got: %v", err)
+			}
+			expected := bellmanFord(g, g.Node(start))
+			for n, d := range distances {
+				if d != expected[n] && math.Abs(d-expected[n]) > 1e-9 {
+					t.Fatalf("%v maze %d: %v expected a distance of %v, but got: %v", connectivity, i, g.Point(Node(n)), expected[n], d)
+				}
+				if p := g.Point(Node(n)); connectivity == FourConnected && !math.IsInf(d, 1) && int(d) != bfsDistance(maze, start, p) {
+					t.Fatalf("maze %d: %v expected the same distance as a path to it", i, p)
+				}
+			}
+		}
+	}
+}
+
+func TestDistanceFieldErrors(t *testing.T) {
+	g := openGrid(5, 5)
+	g.Grid.SetOpen(2, 2, false)
+	if _, err := DistanceField(context.Background(), g, g.Node(image.Pt(2, 2))); !errors.Is(err, ErrStartBlocked) {
+		t.Fatalf("Expected %v, but got: %v", ErrStartBlocked, err)
+	}
+	if _, err := DistanceField(context.Background(), g, -1); !errors.Is(err, ErrOutOfBounds) {
+		t.Fatalf("Expected %v, but got: %v", ErrOutOfBounds, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DistanceField(ctx, g, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected %v, but got: %v", context.Canceled, err)
+	}
+}
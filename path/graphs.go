@@ -0,0 +1,303 @@
+package path
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"mazes/grid"
+)
+
+// steps are the moves to the 4 neighbours of a pixel, in the order grids list them
+var steps = [4]image.Point{{0, -1}, {-1, 0}, {1, 0}, {0, 1}}
+
+// diagonals are the moves to the diagonal neighbours of a pixel
+var diagonals = [4]image.Point{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
+
+// Connectivity is which neighbours of a pixel it's connected to
+type Connectivity int
+
+const (
+	// FourConnected pixels connect to the pixels above, below and next to them
+	FourConnected Connectivity = iota
+	// EightConnected pixels also connect diagonally to the open pixels at their
+	// corners for a cost of √2, even between two walls
+	EightConnected
+	// EightConnectedNoCornerCutting pixels only connect diagonally when both
+	// pixels the diagonal goes between are open
+	EightConnectedNoCornerCutting
+)
+
+func (c Connectivity) String() string {
+	switch c {
+	case FourConnected:
+		return "4"
+	case EightConnected:
+		return "8"
+	case EightConnectedNoCornerCutting:
+		return "8-no-corners"
+	}
+	return fmt.Sprintf("Connectivity(%d)", int(c))
+}
+
+// diagonal reports whether pixels connect diagonally
+func (c Connectivity) diagonal() bool {
+	return c == EightConnected || c == EightConnectedNoCornerCutting
+}
+
+// GridGraph connects the open pixels of a grid to their open neighbours,
+// node x + y*Width is pixel x, y
+type GridGraph struct {
+	Grid         *grid.Grid
+	Connectivity Connectivity
+	// Distance is what A* estimates the rest of the way with, nil is the
+	// DefaultDistance of the connectivity
+	Distance Distance
+}
+
+// NewGridGraph makes a FourConnected graph of g
+func NewGridGraph(g *grid.Grid) GridGraph {
+	return GridGraph{Grid: g}
+}
+
+func (g GridGraph) Len() int {
+	return g.Grid.Width * g.Grid.Height
+}
+
+func (g GridGraph) MaxDegree() int {
+	if g.Connectivity.diagonal() {
+		return len(steps) + len(diagonals)
+	}
+	return len(steps)
+}
+
+// Neighbors of a wall are empty, there'd be no edges going back to it
+func (g GridGraph) Neighbors(n Node, buf []Edge) []Edge {
+	buf = buf[:0]
+	width := g.Grid.Width
+	x, y := int(n)%width, int(n)/width
+	if !g.Grid.Open(x, y) {
+		return buf
+	}
+	for _, step := range steps {
+		if g.Grid.Open(x+step.X, y+step.Y) {
+			buf = append(buf, Edge{To: n + Node(step.Y*width+step.X), Cost: 1})
+		}
+	}
+	if !g.Connectivity.diagonal() {
+		return buf
+	}
+	for _, step := range diagonals {
+		if g.diagonalOpen(x, y, step) {
+			buf = append(buf, Edge{To: n + Node(step.Y*width+step.X), Cost: math.Sqrt2})
+		}
+	}
+	return buf
+}
+
+// diagonalOpen reports whether the diagonal step from x, y can be taken
+func (g GridGraph) diagonalOpen(x, y int, step image.Point) bool {
+	if g.Connectivity == EightConnectedNoCornerCutting && !(g.Grid.Open(x+step.X, y) && g.Grid.Open(x, y+step.Y)) {
+		return false
+	}
+	return g.Grid.Open(x+step.X, y+step.Y)
+}
+
+// Symmetric is true, diagonal steps cost the same both ways like the others
+func (g GridGraph) Symmetric() bool {
+	return true
+}
+
+func (g GridGraph) Open(n Node) bool {
+	p := g.Point(n)
+	return g.Grid.Open(p.X, p.Y)
+}
+
+func (g GridGraph) Estimate(from, to Node) float64 {
+	distance := g.Distance
+	if distance == nil {
+		distance = DefaultDistance(g.Connectivity)
+	}
+	return distance(g.Point(from), g.Point(to))
+}
+
+func (g GridGraph) Node(p image.Point) Node {
+	if !p.In(g.Grid.Bounds()) {
+		return -1
+	}
+	return Node(g.Grid.Index(p.X, p.Y))
+}
+
+func (g GridGraph) Point(n Node) image.Point {
+	return image.Pt(int(n)%g.Grid.Width, int(n)/g.Grid.Width)
+}
+
+// CellGraph connects the cells of a maze through the passages between them,
+// node x + y*Cols is cell x, y
+type CellGraph struct {
+	Maze *grid.CellMaze
+}
+
+func NewCellGraph(m *grid.CellMaze) CellGraph {
+	return CellGraph{Maze: m}
+}
+
+func (g CellGraph) Len() int {
+	return g.Maze.Cols * g.Maze.Rows
+}
+
+func (g CellGraph) MaxDegree() int {
+	return len(steps)
+}
+
+func (g CellGraph) Neighbors(n Node, buf []Edge) []Edge {
+	buf = buf[:0]
+	c := g.Cell(n)
+	passages := g.Maze.Passages(c.X, c.Y)
+	for i, direction := range []grid.Direction{grid.North, grid.West, grid.East, grid.South} {
+		next := c.Add(steps[i])
+		if passages&direction != 0 && next.In(image.Rect(0, 0, g.Maze.Cols, g.Maze.Rows)) {
+			buf = append(buf, Edge{To: g.Node(next), Cost: 1})
+		}
+	}
+	return buf
+}
+
+func (g CellGraph) Symmetric() bool {
+	return true
+}
+
+// Estimate is the number of cells between the two, as if there were no walls
+func (g CellGraph) Estimate(from, to Node) float64 {
+	d := g.Cell(from).Sub(g.Cell(to))
+	return float64(AbsInt(d.X) + AbsInt(d.Y))
+}
+
+func (g CellGraph) Node(cell image.Point) Node {
+	if !cell.In(image.Rect(0, 0, g.Maze.Cols, g.Maze.Rows)) {
+		return -1
+	}
+	return Node(cell.Y*g.Maze.Cols + cell.X)
+}
+
+func (g CellGraph) Cell(n Node) image.Point {
+	return image.Pt(int(n)%g.Maze.Cols, int(n)/g.Maze.Cols)
+}
+
+// WeightedGrid is a 4-connected grid where stepping on a pixel costs its
+// weight, pixels weighing +Inf are walls. Node x + y*Width is pixel x, y.
+type WeightedGrid struct {
+	Width, Height int
+	// Distance is what A* estimates the rest of the way with, times the
+	// lowest weight. nil is Manhattan.
+	Distance Distance
+	costs    []float64
+	minCost  float64
+}
+
+// NewWeightedGrid takes the weights of the pixels row by row, they have to be positive
+func NewWeightedGrid(width, height int, costs []float64) (*WeightedGrid, error) {
+	if len(costs) != width*height {
+		return nil, fmt.Errorf("expected %d costs for a %dx%d grid, got: %d", width*height, width, height, len(costs))
+	}
+	g := &WeightedGrid{Width: width, Height: height, costs: costs, minCost: math.Inf(1)}
+	for i, cost := range costs {
+		if !(cost > 0) {
+			return nil, fmt.Errorf("cost of pixel (%d, %d) should be positive, got: %v", i%width, i/width, cost)
+		}
+		g.minCost = min(g.minCost, cost)
+	}
+	return g, nil
+}
+
+func (g *WeightedGrid) Len() int {
+	return g.Width * g.Height
+}
+
+func (g *WeightedGrid) MaxDegree() int {
+	return len(steps)
+}
+
+func (g *WeightedGrid) Cost(p image.Point) float64 {
+	if !p.In(image.Rect(0, 0, g.Width, g.Height)) {
+		return math.Inf(1)
+	}
+	return g.costs[p.Y*g.Width+p.X]
+}
+
+// Neighbors of a wall are empty, there'd be no edges going back to it
+func (g *WeightedGrid) Neighbors(n Node, buf []Edge) []Edge {
+	buf = buf[:0]
+	if !g.Open(n) {
+		return buf
+	}
+	p := g.Point(n)
+	for _, step := range steps {
+		next := p.Add(step)
+		if cost := g.Cost(next); !math.IsInf(cost, 1) {
+			buf = append(buf, Edge{To: g.Node(next), Cost: cost})
+		}
+	}
+	return buf
+}
+
+func (g *WeightedGrid) Open(n Node) bool {
+	return !math.IsInf(g.costs[n], 1)
+}
+
+// Estimate is the Distance between the pixels as if they all had the lowest weight
+func (g *WeightedGrid) Estimate(from, to Node) float64 {
+	if math.IsInf(g.minCost, 1) {
+		return 0
+	}
+	distance := g.Distance
+	if distance == nil {
+		distance = Manhattan
+	}
+	return distance(g.Point(from), g.Point(to)) * g.minCost
+}
+
+func (g *WeightedGrid) Node(p image.Point) Node {
+	if !p.In(image.Rect(0, 0, g.Width, g.Height)) {
+		return -1
+	}
+	return Node(p.Y*g.Width + p.X)
+}
+
+func (g *WeightedGrid) Point(n Node) image.Point {
+	return image.Pt(int(n)%g.Width, int(n)/g.Width)
+}
+
+// AdjacencyList is a graph with its edges listed one by one, for mazes that
+// aren't square grids, like hex or 3D mazes, or graphs of junctions
+type AdjacencyList struct {
+	edges     [][]Edge
+	maxDegree int
+}
+
+func NewAdjacencyList(nodes int) *AdjacencyList {
+	return &AdjacencyList{edges: make([][]Edge, nodes)}
+}
+
+// AddEdge connects a and b both ways with the same cost
+func (l *AdjacencyList) AddEdge(a, b Node, cost float64) {
+	l.edges[a] = append(l.edges[a], Edge{To: b, Cost: cost})
+	l.edges[b] = append(l.edges[b], Edge{To: a, Cost: cost})
+	l.maxDegree = max(l.maxDegree, len(l.edges[a]), len(l.edges[b]))
+}
+
+func (l *AdjacencyList) Len() int {
+	return len(l.edges)
+}
+
+func (l *AdjacencyList) MaxDegree() int {
+	return l.maxDegree
+}
+
+func (l *AdjacencyList) Neighbors(n Node, buf []Edge) []Edge {
+	return append(buf[:0], l.edges[n]...)
+}
+
+// Symmetric is true, AddEdge gives both ways the same cost
+func (l *AdjacencyList) Symmetric() bool {
+	return true
+}
@@ -0,0 +1,254 @@
+package path
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"mazes/grid"
+	"testing"
+)
+
+// checkEdges fails unless every edge of g has one going back
+func checkEdges(t *testing.T, g Graph) {
+	t.Helper()
+	var edges, back []Edge
+	symmetric, _ := g.(Symmetric)
+	for n := Node(0); int(n) < g.Len(); n++ {
+		edges = g.Neighbors(n, edges)
+		if len(edges) > g.MaxDegree() {
+			t.Fatalf("Expected at most %d edges, but %d has: %v", g.MaxDegree(), n, edges)
+		}
+		for _, edge := range edges {
+			back = g.Neighbors(edge.To, back)
+			found := false
+			for _, b := range back {
+				if b.To == n {
+					found = true
+					if symmetric != nil && symmetric.Symmetric() && b.Cost != edge.Cost {
+						t.Fatalf("Expected the edge from %d back to %d to cost %v, but got: %v", edge.To, n, edge.Cost, b.Cost)
+					}
+				}
+			}
+			if !found {
+				t.Fatalf("Expected an edge from %d back to %d", edge.To, n)
+			}
+		}
+	}
+}
+
+// hexDistance is the number of steps between two hexes in axial coordinates
+func hexDistance(a, b image.Point) int {
+	d := a.Sub(b)
+	return (AbsInt(d.X) + AbsInt(d.Y) + AbsInt(d.X+d.Y)) / 2
+}
+
+func TestAdjacencyListHexGrid(t *testing.T) {
+	const size = 12
+	hex := func(n Node) image.Point {
+		return image.Pt(int(n)%size, int(n)/size)
+	}
+	g := NewAdjacencyList(size * size)
+	for n := Node(0); n < size*size; n++ {
+		h := hex(n)
+		for _, step := range []image.Point{{1, 0}, {0, 1}, {-1, 1}} {
+			if next := h.Add(step); next.In(image.Rect(0, 0, size, size)) {
+				g.AddEdge(n, Node(next.Y*size+next.X), 1)
+			}
+		}
+	}
+	if g.MaxDegree() != 6 {
+		t.Fatalf("Expected a degree of 6, but got: %d", g.MaxDegree())
+	}
+	checkEdges(t, g)
+	withHeuristic := WithHeuristic(g, func(from, to Node) float64 {
+		return float64(hexDistance(hex(from), hex(to)))
+	})
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		start, goal := Node(r.Intn(size*size)), Node(r.Intn(size*size))
+		for _, graph := range []Graph{g, withHeuristic} {
+			for _, name := range guaranteeing(FewestEdges) {
+				solver, _ := Lookup(name)
+				res, err := solver.Solve(context.Background(), graph, start, goal)
+				if err != nil {
+					t.Fatalf("%s: expected no error, but got: %v", name, err)
+				}
+				if expected := hexDistance(hex(start), hex(goal)); res.Cost != float64(expected) || len(res.Path)-1 != expected {
+					t.Fatalf("%s: expected %d steps from %v to %v, but got: %v", name, expected, hex(start), hex(goal), res.Path)
+				}
+			}
+		}
+	}
+}
+
+// bellmanFord is the cost of the cheapest path from start to every node, +Inf for the ones it can't reach
+func bellmanFord(g Graph, start Node) []float64 {
+	costs := make([]float64, g.Len())
+	for i := range costs {
+		costs[i] = math.Inf(1)
+	}
+	costs[start] = 0
+	var edges []Edge
+	for changed := true; changed; {
+		changed = false
+		for n := Node(0); int(n) < g.Len(); n++ {
+			edges = g.Neighbors(n, edges)
+			for _, edge := range edges {
+				if costs[n]+edge.Cost < costs[edge.To] {
+					costs[edge.To] = costs[n] + edge.Cost
+					changed = true
+				}
+			}
+		}
+	}
+	return costs
+}
+
+func TestWeightedGridCheapestPaths(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		width, height := 1+r.Intn(15), 1+r.Intn(15)
+		costs := make([]float64, width*height)
+		for j := range costs {
+			costs[j] = 1 + float64(r.Intn(9))
+			if r.Float64() < 0.2 {
+				costs[j] = math.Inf(1)
+			}
+		}
+		g, err := NewWeightedGrid(width, height, costs)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		checkEdges(t, g)
+		start, goal := Node(r.Intn(width*height)), Node(r.Intn(width*height))
+		if !g.Open(start) || !g.Open(goal) {
+			continue
+		}
+		expected := bellmanFord(g, start)[goal]
+
+		// the bfs solvers ignore costs
+		for _, name := range guaranteeing(Cheapest) {
+			solver, _ := Lookup(name)
+			res, err := solver.Solve(context.Background(), g, start, goal)
+			if math.IsInf(expected, 1) {
+				if !errors.Is(err, ErrNoPath) {
+					t.Fatalf("%s on grid %d: expected %v, but got: %v", name, i, ErrNoPath, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s on grid %d: expected no error, but got: %v", name, i, err)
+			}
+			cost := 0.0
+			for _, n := range res.Path[1:] {
+				cost += g.Cost(g.Point(n))
+			}
+			if res.Cost != expected || cost != expected {
+				t.Fatalf("%s on grid %d: expected a cost of %v, but got: %v for a path costing %v", name, i, expected, res.Cost, cost)
+			}
+		}
+	}
+}
+
+func TestWeightedGridEstimate(t *testing.T) {
+	g, err := NewWeightedGrid(4, 3, []float64{2, 3, 4, 5, 2, 2, 2, 2, 9, 9, 9, 9})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	from, to := g.Node(image.Pt(0, 0)), g.Node(image.Pt(3, 2))
+	if got := g.Estimate(from, to); got != 10 {
+		t.Fatalf("Expected 5 steps of the lowest weight 2, but got: %v", got)
+	}
+	g.Distance = Weighted(Chebyshev, 1.5)
+	if got := g.Estimate(from, to); got != 9 {
+		t.Fatalf("Expected 1.5 times 3 steps of weight 2, but got: %v", got)
+	}
+}
+
+func TestNewWeightedGridErrors(t *testing.T) {
+	if _, err := NewWeightedGrid(2, 2, []float64{1, 1, 1}); err == nil {
+		t.Fatalf("Expected an error for missing costs")
+	}
+	for _, cost := range []float64{0, -1, math.NaN()} {
+		if _, err := NewWeightedGrid(2, 1, []float64{1, cost}); err == nil {
+			t.Fatalf("Expected an error for a cost of %v", cost)
+		}
+	}
+}
+
+func TestCellGraph(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		cols, rows := 1+r.Intn(20), 1+r.Intn(20)
+		g := randomBraidedMaze(r, cols, rows, r.Float64()*0.5)
+		// with 1 pixel cells and walls the pixels are the cell grid itself
+		cells := NewCellGraph(grid.Lattice{CellSize: 1, WallThickness: 1, Cols: cols, Rows: rows}.Cells(g))
+		checkEdges(t, NewGridGraph(g))
+		checkEdges(t, cells)
+		start, end := image.Pt(r.Intn(cols), r.Intn(rows)), image.Pt(r.Intn(cols), r.Intn(rows))
+		expected := bfsDistance(g, image.Pt(2*start.X+1, 2*start.Y+1), image.Pt(2*end.X+1, 2*end.Y+1)) / 2
+
+		for _, name := range guaranteeing(FewestEdges) {
+			solver, _ := Lookup(name)
+			res, err := solver.Solve(context.Background(), cells, cells.Node(start), cells.Node(end))
+			if err != nil {
+				t.Fatalf("%s on maze %d: expected no error, but got: %v", name, i, err)
+			}
+			if len(res.Path)-1 != expected {
+				t.Fatalf("%s on maze %d: expected %d cells from %v to %v, but got: %d", name, i, expected, start, end, len(res.Path)-1)
+			}
+			for j, n := range res.Path[1:] {
+				d := cells.Cell(n).Sub(cells.Cell(res.Path[j]))
+				if AbsInt(d.X)+AbsInt(d.Y) != 1 {
+					t.Fatalf("%s on maze %d: expected %v to be next to %v", name, i, cells.Cell(n), cells.Cell(res.Path[j]))
+				}
+			}
+		}
+	}
+}
+
+func TestWeightedGridFromImage(t *testing.T) {
+	// a white image with a black wall across it except for the bottom row
+	img := image.NewNRGBA(image.Rect(0, 0, 7, 5))
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 7; x++ {
+			img.Set(x, y, color.White)
+			if x == 3 && y < 4 {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	cost := grid.ColorCosts(grid.LuminanceCost(1, 100), grid.ColorCost{Color: red, Cost: math.Inf(1)})
+	start, end := image.Pt(0, 0), image.Pt(6, 0)
+
+	for _, name := range []string{"dijkstra", "astar"} {
+		solver, _ := Lookup(name)
+		m := grid.CostsFromImage(img, cost)
+		g, err := NewWeightedGrid(m.Width, m.Height, m.Costs)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		// going around the black wall is cheaper than crossing it
+		res, err := solver.Solve(context.Background(), g, g.Node(start), g.Node(end))
+		if err != nil || res.Cost != 14 {
+			t.Fatalf("%s: expected a cost of 14, but got: %v, %v", name, res.Cost, err)
+		}
+
+		// unless a red pixel closes the way around
+		img.Set(2, 4, red)
+		m = grid.CostsFromImage(img, cost)
+		if g, err = NewWeightedGrid(m.Width, m.Height, m.Costs); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		res, err = solver.Solve(context.Background(), g, g.Node(start), g.Node(end))
+		if err != nil || res.Cost != 105 {
+			t.Fatalf("%s: expected a cost of 105, but got: %v, %v", name, res.Cost, err)
+		}
+		img.Set(2, 4, color.White)
+	}
+}
@@ -0,0 +1,59 @@
+package path
+
+import "container/heap"
+
+// nodeHeap is a binary min heap of the queued nodes ordered by distance plus
+// distanceRemaining. Ties go to the node with the longest distance, the one
+// closest to the end, and then to the one queued first, so paths don't depend
+// on the layout of the heap. Nodes keep their position in it, so a node whose
+// distance goes down is moved up in place instead of being queued again.
+type nodeHeap []*DNode
+
+func (h nodeHeap) Len() int {
+	return len(h)
+}
+
+func (h nodeHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	if a.priority() != b.priority() {
+		return a.priority() < b.priority()
+	}
+	if a.distance != b.distance {
+		return a.distance > b.distance
+	}
+	return a.order < b.order
+}
+
+func (h nodeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *nodeHeap) Push(x any) {
+	node := x.(*DNode)
+	node.index = len(*h)
+	*h = append(*h, node)
+}
+
+func (h *nodeHeap) Pop() any {
+	old := *h
+	node := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	node.index = -1
+	return node
+}
+
+func (h *nodeHeap) push(node *DNode) {
+	heap.Push(h, node)
+}
+
+func (h *nodeHeap) pop() *DNode {
+	return heap.Pop(h).(*DNode)
+}
+
+// decreased restores the order after the priority of node went down
+func (h *nodeHeap) decreased(node *DNode) {
+	heap.Fix(h, node.index)
+}
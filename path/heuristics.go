@@ -0,0 +1,67 @@
+package path
+
+import (
+	"image"
+	"math"
+)
+
+// Distance estimates the cost between two pixels of a grid, for A* on a GridGraph
+type Distance func(a, b image.Point) float64
+
+// Manhattan is exact on an open FourConnected grid, it overestimates diagonal moves
+func Manhattan(a, b image.Point) float64 {
+	d := a.Sub(b)
+	return float64(AbsInt(d.X) + AbsInt(d.Y))
+}
+
+// Octile is exact on an open grid with diagonal moves costing √2
+func Octile(a, b image.Point) float64 {
+	dx, dy := AbsInt(a.X-b.X), AbsInt(a.Y-b.Y)
+	return float64(max(dx, dy)-min(dx, dy)) + math.Sqrt2*float64(min(dx, dy))
+}
+
+// Chebyshev counts diagonal moves as 1, it never overestimates but is weaker than Octile
+func Chebyshev(a, b image.Point) float64 {
+	return float64(max(AbsInt(a.X-b.X), AbsInt(a.Y-b.Y)))
+}
+
+func Euclidean(a, b image.Point) float64 {
+	d := a.Sub(b)
+	return math.Sqrt(float64(d.X*d.X + d.Y*d.Y))
+}
+
+// Zero turns A* into Dijkstra
+func Zero(a, b image.Point) float64 {
+	return 0
+}
+
+// Weighted multiplies d by epsilon. With an epsilon above 1 A* expands fewer
+// nodes but its paths can be up to epsilon times longer than the shortest one.
+func Weighted(d Distance, epsilon float64) Distance {
+	return func(a, b image.Point) float64 {
+		return epsilon * d(a, b)
+	}
+}
+
+// DefaultDistance is the exact distance on an open grid with connectivity c
+func DefaultDistance(c Connectivity) Distance {
+	if c.diagonal() {
+		return Octile
+	}
+	return Manhattan
+}
+
+// distances are the Distances that can be picked by name
+var distances = map[string]Distance{
+	"manhattan": Manhattan,
+	"octile":    Octile,
+	"chebyshev": Chebyshev,
+	"euclidean": Euclidean,
+	"zero":      Zero,
+}
+
+// LookupDistance finds a Distance by its name in lower case
+func LookupDistance(name string) (Distance, bool) {
+	d, ok := distances[name]
+	return d, ok
+}
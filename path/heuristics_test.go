@@ -0,0 +1,90 @@
+package path
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistances(t *testing.T) {
+	a, b := image.Pt(1, 2), image.Pt(4, -2)
+	tests := []struct {
+		name     string
+		distance Distance
+		expected float64
+	}{
+		{"manhattan", Manhattan, 7},
+		{"octile", Octile, 1 + 3*math.Sqrt2},
+		{"chebyshev", Chebyshev, 4},
+		{"euclidean", Euclidean, 5},
+		{"zero", Zero, 0},
+	}
+	for _, test := range tests {
+		if got := test.distance(a, b); math.Abs(got-test.expected) > 1e-9 {
+			t.Fatalf("%s: expected %v, but got: %v", test.name, test.expected, got)
+		}
+		if d, ok := LookupDistance(test.name); !ok || d(a, b) != test.distance(a, b) {
+			t.Fatalf("Expected %s to be found by name", test.name)
+		}
+	}
+	if got := Weighted(Manhattan, 1.5)(a, b); got != 10.5 {
+		t.Fatalf("Expected 10.5, but got: %v", got)
+	}
+}
+
+func TestConnectivityAndDistances(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	// the distances A* finds shortest paths with, for every connectivity
+	admissible := map[Connectivity][]Distance{
+		FourConnected:                 {Manhattan, Octile, Chebyshev, Euclidean, Zero},
+		EightConnected:                {Octile, Chebyshev, Euclidean, Zero},
+		EightConnectedNoCornerCutting: {Octile, Chebyshev, Euclidean, Zero},
+	}
+	for _, connectivity := range []Connectivity{FourConnected, EightConnected, EightConnectedNoCornerCutting} {
+		distances := admissible[connectivity]
+		for i := 0; i < 100; i++ {
+			width, height := 1+r.Intn(20), 1+r.Intn(20)
+			g := GridGraph{Grid: randomRooms(r, width, height, r.Float64()*0.4), Connectivity: connectivity}
+			checkEdges(t, g)
+			start, goal := Node(r.Intn(g.Len())), Node(r.Intn(g.Len()))
+			if !g.Open(start) || !g.Open(goal) {
+				continue
+			}
+			expected := bellmanFord(g, start)[goal]
+
+			for _, distance := range distances {
+				g.Distance = distance
+				for _, name := range []string{"astar", "bidirectional-astar"} {
+					solver, _ := Lookup(name)
+					checkCheapest(t, solver, g, start, goal, expected, 1)
+				}
+			}
+			// paths with an epsilon of 2 can be up to twice as long
+			g.Distance = Weighted(Octile, 2)
+			checkCheapest(t, AStar, g, start, goal, expected, 2)
+		}
+	}
+}
+
+// checkCheapest fails unless solver finds a path from start to goal costing
+// at most stretch times expected, or no path when expected is +Inf
+func checkCheapest(t *testing.T, solver Solver, g Graph, start, goal Node, expected, stretch float64) {
+	t.Helper()
+	res, err := solver.Solve(context.Background(), g, start, goal)
+	if math.IsInf(expected, 1) {
+		if !errors.Is(err, ErrNoPath) {
+			t.Fatalf("Expected %v, but got: %v", ErrNoPath, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	cost := checkGraphPath(t, g, res.Path, start, goal)
+	if cost > stretch*expected+1e-9 || math.Abs(cost-res.Cost) > 1e-9 {
+		t.Fatalf("Expected a cost of at most %v, but got: %v for a path costing %v", stretch*expected, res.Cost, cost)
+	}
+}
@@ -0,0 +1,257 @@
+package path
+
+import (
+	"context"
+	"image"
+	"mazes/grid"
+	"slices"
+	"time"
+)
+
+// JPS is A* on pixel grids that jumps over the pixels of open areas that
+// every shortest path could go through in some other order, and only queues
+// the jump points where paths have to turn. It finds paths as short as A*
+// does. On graphs other than a GridGraph it's plain A*.
+var JPS Solver = guaranteed{jps, Cheapest}
+
+func init() {
+	Register("jps", JPS)
+}
+
+type jumper struct {
+	g    GridGraph
+	goal image.Point
+}
+
+func (j *jumper) open(x, y int) bool {
+	return j.g.Grid.Open(x, y)
+}
+
+// jump walks from p in direction d until it finds a jump point: the goal or
+// a pixel with a neighbour only reached in as few steps by turning there. It
+// returns false when it runs into a wall first.
+func (j *jumper) jump(p, d image.Point) (image.Point, bool) {
+	diagonal := d.X != 0 && d.Y != 0
+	for {
+		if !j.canStep(p, d) {
+			return p, false
+		}
+		p = p.Add(d)
+		if p == j.goal {
+			return p, true
+		}
+		if j.g.Connectivity == EightConnected && j.forced8(p, d) ||
+			j.g.Connectivity != EightConnected && !diagonal && j.forcedStraight(p, d) {
+			return p, true
+		}
+		if !diagonal && (j.g.Connectivity != FourConnected || d.Y == 0) {
+			continue
+		}
+		// diagonal moves, and vertical ones when there are no diagonals, stop
+		// where a move across would find something
+		across := []image.Point{{d.X, 0}, {0, d.Y}}
+		if !diagonal {
+			across = []image.Point{{1, 0}, {-1, 0}}
+		}
+		for _, a := range across {
+			if _, ok := j.jump(p, a); ok {
+				return p, true
+			}
+		}
+	}
+}
+
+func (j *jumper) canStep(p, d image.Point) bool {
+	if d.X != 0 && d.Y != 0 {
+		return j.g.diagonalOpen(p.X, p.Y, d)
+	}
+	return j.open(p.X+d.X, p.Y+d.Y)
+}
+
+// forcedStraight reports whether arriving at p in straight direction d, an
+// open pixel at the side of p has a wall behind it, so it's best reached by
+// turning at p when pixels can't be passed diagonally at corners
+func (j *jumper) forcedStraight(p, d image.Point) bool {
+	side := image.Pt(d.Y, d.X)
+	for _, s := range []image.Point{side, side.Mul(-1)} {
+		if j.open(p.X+s.X, p.Y+s.Y) && !j.open(p.X+s.X-d.X, p.Y+s.Y-d.Y) {
+			return true
+		}
+	}
+	return false
+}
+
+// forced8 reports whether arriving at p in direction d, a wall next to p
+// makes one of its neighbours only reachable at the same cost through p
+func (j *jumper) forced8(p, d image.Point) bool {
+	switch {
+	case d.X != 0 && d.Y != 0:
+		return !j.open(p.X-d.X, p.Y) && j.open(p.X-d.X, p.Y+d.Y) ||
+			!j.open(p.X, p.Y-d.Y) && j.open(p.X+d.X, p.Y-d.Y)
+	case d.X != 0:
+		return !j.open(p.X, p.Y+1) && j.open(p.X+d.X, p.Y+1) ||
+			!j.open(p.X, p.Y-1) && j.open(p.X+d.X, p.Y-1)
+	default:
+		return !j.open(p.X+1, p.Y) && j.open(p.X+1, p.Y+d.Y) ||
+			!j.open(p.X-1, p.Y) && j.open(p.X-1, p.Y+d.Y)
+	}
+}
+
+// directions are where to jump from p when it was reached going in direction
+// d, the zero point for the start. The pixels behind p were already reached as
+// cheaply some other way.
+func (j *jumper) directions(p, d image.Point) []image.Point {
+	if d == (image.Point{}) {
+		if j.g.Connectivity.diagonal() {
+			return append(steps[:], diagonals[:]...)
+		}
+		return steps[:]
+	}
+	diagonal := d.X != 0 && d.Y != 0
+	side := image.Pt(d.Y, d.X)
+	switch {
+	case j.g.Connectivity == FourConnected:
+		return []image.Point{d, side, side.Mul(-1)}
+	case j.g.Connectivity == EightConnectedNoCornerCutting && diagonal:
+		return []image.Point{d, {d.X, 0}, {0, d.Y}}
+	case j.g.Connectivity == EightConnectedNoCornerCutting:
+		return []image.Point{d, side, side.Mul(-1), d.Add(side), d.Sub(side)}
+	}
+
+	dirs := []image.Point{d}
+	switch {
+	case diagonal:
+		dirs = append(dirs, image.Pt(d.X, 0), image.Pt(0, d.Y))
+		if !j.open(p.X-d.X, p.Y) {
+			dirs = append(dirs, image.Pt(-d.X, d.Y))
+		}
+		if !j.open(p.X, p.Y-d.Y) {
+			dirs = append(dirs, image.Pt(d.X, -d.Y))
+		}
+	case d.X != 0:
+		for _, dy := range []int{-1, 1} {
+			if !j.open(p.X, p.Y+dy) {
+				dirs = append(dirs, image.Pt(d.X, dy))
+			}
+		}
+	default:
+		for _, dx := range []int{-1, 1} {
+			if !j.open(p.X+dx, p.Y) {
+				dirs = append(dirs, image.Pt(dx, d.Y))
+			}
+		}
+	}
+	return dirs
+}
+
+// cost of going in a straight or diagonal line from a to b
+func (j *jumper) cost(a, b image.Point) float64 {
+	if !j.g.Connectivity.diagonal() {
+		return Manhattan(a, b)
+	}
+	return Octile(a, b)
+}
+
+func direction(from, to image.Point) image.Point {
+	return image.Pt(sign(to.X-from.X), sign(to.Y-from.Y))
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+// jps is dijkstraOrAStar on the jump points. They're few, so their parents are
+// kept in a map instead of the per node bits the other solvers use.
+func jps(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	gg, ok := g.(GridGraph)
+	if !ok {
+		return dijkstraOrAStar(ctx, true, g, start, goal)
+	}
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	j := &jumper{g: gg, goal: gg.Point(goal)}
+	trace := newTracer(ctx)
+
+	closed := grid.NewBitset(g.Len())
+	parent := make(map[Node]Node)
+	open := make(map[Node]*DNode)
+	pqueue := make(nodeHeap, 0)
+	queued := 0
+	startNode := &DNode{Node: start}
+	open[start] = startNode
+	pqueue.push(startNode)
+
+	expanded := 0
+	for pqueue.Len() > 0 {
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		top := pqueue.pop()
+		delete(open, top.Node)
+		closed.Set(int(top.Node))
+		if top.Node == goal {
+			return Result{
+				Path:     j.fill(parent, start, goal),
+				Cost:     top.distance,
+				Expanded: expanded,
+				Elapsed:  time.Since(began),
+			}, nil
+		}
+		expanded++
+
+		p := gg.Point(top.Node)
+		var d image.Point
+		if top.Node != start {
+			d = direction(gg.Point(parent[top.Node]), p)
+		}
+		for _, dir := range j.directions(p, d) {
+			next, ok := j.jump(p, dir)
+			if !ok || closed.Has(gg.Grid.Index(next.X, next.Y)) {
+				continue
+			}
+			n := gg.Node(next)
+			newDistance := top.distance + j.cost(p, next)
+			childNode, ok := open[n]
+			if ok && newDistance >= childNode.distance {
+				continue
+			}
+			parent[n] = top.Node
+			trace.push(n)
+			if ok {
+				childNode.distance = newDistance
+				pqueue.decreased(childNode)
+				continue
+			}
+			queued++
+			childNode = &DNode{Node: n, distance: newDistance, distanceRemaining: gg.Estimate(n, goal), order: queued}
+			open[n] = childNode
+			pqueue.push(childNode)
+		}
+		trace.expand(top.Node, pqueue.Len())
+	}
+	return Result{}, ErrNoPath
+}
+
+// fill walks the jump points back from goal and adds the pixels between them
+func (j *jumper) fill(parent map[Node]Node, start, goal Node) []Node {
+	path := []Node{goal}
+	for curr := goal; curr != start; {
+		from, to := j.g.Point(parent[curr]), j.g.Point(curr)
+		d := direction(to, from)
+		for p := to.Add(d); p != from; p = p.Add(d) {
+			path = append(path, j.g.Node(p))
+		}
+		curr = parent[curr]
+		path = append(path, curr)
+	}
+	slices.Reverse(path)
+	return path
+}
@@ -0,0 +1,93 @@
+package path
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"mazes/grid"
+	"testing"
+)
+
+// randomRooms is an open grid with walls scattered over it at the given density
+func randomRooms(r *rand.Rand, width, height int, density float64) *grid.Grid {
+	g := grid.New(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			g.SetOpen(x, y, r.Float64() >= density)
+		}
+	}
+	return g
+}
+
+// checkGraphPath fails unless every step of path is an edge of g, and returns the cost of the path
+func checkGraphPath(t *testing.T, g Graph, path []Node, start, goal Node) float64 {
+	t.Helper()
+	if len(path) == 0 || path[0] != start || path[len(path)-1] != goal {
+		t.Fatalf("Expected a path from %d to %d, but got: %v", start, goal, path)
+	}
+	cost := 0.0
+	var edges []Edge
+	for i := 1; i < len(path); i++ {
+		edges = g.Neighbors(path[i-1], edges)
+		found := false
+		for _, edge := range edges {
+			if edge.To == path[i] {
+				cost += edge.Cost
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("Expected an edge from %d to %d in %v", path[i-1], path[i], path)
+		}
+	}
+	return cost
+}
+
+func TestJPSMatchesAStar(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, connectivity := range []Connectivity{FourConnected, EightConnected, EightConnectedNoCornerCutting} {
+		for i := 0; i < 500; i++ {
+			width, height := 1+r.Intn(30), 1+r.Intn(30)
+			g := GridGraph{Grid: randomRooms(r, width, height, r.Float64()*0.4), Connectivity: connectivity}
+			start := g.Node(image.Pt(r.Intn(width), r.Intn(height)))
+			goal := g.Node(image.Pt(r.Intn(width), r.Intn(height)))
+			g.Grid.SetOpen(g.Point(start).X, g.Point(start).Y, true)
+			g.Grid.SetOpen(g.Point(goal).X, g.Point(goal).Y, true)
+			name := fmt.Sprintf("grid %d with connectivity %v", i, connectivity)
+
+			expected, err := AStar.Solve(context.Background(), g, start, goal)
+			res, jpsErr := JPS.Solve(context.Background(), g, start, goal)
+			if errors.Is(err, ErrNoPath) {
+				if !errors.Is(jpsErr, ErrNoPath) {
+					t.Fatalf("%s: expected %v, but got: %v", name, ErrNoPath, jpsErr)
+				}
+				continue
+			}
+			if jpsErr != nil {
+				t.Fatalf("%s: expected no error, but got: %v", name, jpsErr)
+			}
+			cost := checkGraphPath(t, g, res.Path, start, goal)
+			if math.Abs(res.Cost-expected.Cost) > 1e-9 || math.Abs(cost-expected.Cost) > 1e-9 {
+				t.Fatalf("%s: expected a cost of %v, but got: %v for a path costing %v", name, expected.Cost, res.Cost, cost)
+			}
+		}
+	}
+}
+
+func BenchmarkJPSOpenGrid(b *testing.B) {
+	for _, solver := range []string{"astar", "jps"} {
+		b.Run(solver, func(b *testing.B) {
+			s, _ := Lookup(solver)
+			g := openGrid(601, 601)
+			var res Result
+			for i := 0; i < b.N; i++ {
+				res, _ = s.Solve(context.Background(), g, g.Node(image.Pt(0, 0)), g.Node(image.Pt(600, 600)))
+			}
+			b.ReportMetric(float64(res.Expanded), "expanded/op")
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package path
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"mazes/grid"
+	"slices"
+	"time"
+)
+
+// The classic ways of solving a maze by hand. They're meant for perfect mazes,
+// where there's a single path between any two cells, and only the shortest
+// paths finder is sure to find the shortest path on mazes with loops.
+var (
+	// DeadEndFilling fills every dead end and the corridors leading to it
+	// until only the passages between start and goal are left, then follows them
+	DeadEndFilling Solver = guaranteed{deadEndFilling, AnyPath}
+	// CulDeSacFilling also fills the loops hanging off the passages between
+	// start and goal by a single node, and everything beyond them
+	CulDeSacFilling Solver = guaranteed{culDeSacFilling, AnyPath}
+	// LeftHandWallFollower keeps a hand on the wall to its left. It only
+	// works on FourConnected grids and only reaches goals on the same wall
+	// as start.
+	LeftHandWallFollower Solver = guaranteed{wallFollower(false), AnyPath}
+	// RightHandWallFollower keeps a hand on the wall to its right
+	RightHandWallFollower Solver = guaranteed{wallFollower(true), AnyPath}
+	// Pledge walks straight towards goal and follows walls it runs into until
+	// its turns add up to none. It's made for getting out of a maze, so it
+	// gives up when it doesn't run into goal on the way.
+	Pledge Solver = guaranteed{pledge, AnyPath}
+	// Tremaux marks every passage it walks through and never takes one marked
+	// twice, so it walks each of them at most twice. The passages marked once
+	// are the way back to start.
+	Tremaux Solver = guaranteed{tremaux, AnyPath}
+	// ShortestPathsFinder floods the maze from start, which finds every
+	// shortest path at once, then walks one of them back from goal
+	ShortestPathsFinder Solver = guaranteed{shortestPathsFinder, Cheapest}
+)
+
+func init() {
+	Register("dead-end-filling", DeadEndFilling)
+	Register("cul-de-sac-filling", CulDeSacFilling)
+	Register("wall-follower-left", LeftHandWallFollower)
+	Register("wall-follower-right", RightHandWallFollower)
+	Register("pledge", Pledge)
+	Register("tremaux", Tremaux)
+	Register("shortest-paths-finder", ShortestPathsFinder)
+}
+
+// depthFirst walks from start until it finds goal, only stepping on the nodes
+// allowed, nil allows every node. The nodes it has to walk back through to
+// get to start are the path.
+func depthFirst(ctx context.Context, g Graph, start, goal Node, allowed func(Node) bool, expanded *int) ([]Node, error) {
+	type frame struct {
+		node Node
+		// next is the index of the next edge to try
+		next int
+	}
+	trace := newTracer(ctx)
+	seen := grid.NewBitset(g.Len())
+	seen.Set(int(start))
+	stack := []frame{{node: start}}
+	edges := make([]Edge, 0, g.MaxDegree())
+	for len(stack) > 0 {
+		if *expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		top := &stack[len(stack)-1]
+		if top.node == goal {
+			path := make([]Node, len(stack))
+			for i, f := range stack {
+				path[i] = f.node
+			}
+			return path, nil
+		}
+		edges = g.Neighbors(top.node, edges)
+		for ; top.next < len(edges); top.next++ {
+			to := edges[top.next].To
+			if !seen.Has(int(to)) && (allowed == nil || allowed(to)) {
+				break
+			}
+		}
+		if top.next == len(edges) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		to := edges[top.next].To
+		top.next++
+		seen.Set(int(to))
+		stack = append(stack, frame{node: to})
+		*expanded++
+		trace.expand(to, len(stack))
+	}
+	return nil, ErrNoPath
+}
+
+// tremaux walks through the passage with the fewest marks, except that when it
+// gets to a node it's been to through a passage marked once it turns back.
+// Nodes are junctions and passages edges, the marks are kept at both ends of
+// an edge.
+func tremaux(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	trace := newTracer(ctx)
+	degree := g.MaxDegree()
+	// 0 to 2 marks for every edge of every node, packed like parents
+	marks := newParents(g.Len()*degree, 3)
+	mark := func(n Node, edge int) Node {
+		return n*Node(degree) + Node(edge)
+	}
+	visited := grid.NewBitset(g.Len())
+	visited.Set(int(start))
+	edges := make([]Edge, 0, degree)
+	buf := make([]Edge, 0, degree)
+	// back is the edge of curr it came through and revisit whether it had
+	// been to curr before
+	curr, back, revisit := start, -1, false
+	// marked once is how many passages lead back to start
+	markedOnce := 0
+	steps := 0
+	for ; curr != goal; steps++ {
+		if steps%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		edges = g.Neighbors(curr, edges)
+		next := back
+		if !revisit || marks.get(mark(curr, back)) != 1 {
+			next = -1
+			for i := range edges {
+				if next < 0 || marks.get(mark(curr, i)) < marks.get(mark(curr, next)) {
+					next = i
+				}
+			}
+			if next < 0 || marks.get(mark(curr, next)) == 2 {
+				return Result{}, ErrNoPath
+			}
+		}
+
+		to := edges[next].To
+		var err error
+		var toBack int
+		if buf, toBack, err = reverseEdge(g, curr, to, buf); err != nil {
+			return Result{}, err
+		}
+		m := marks.get(mark(curr, next)) + 1
+		marks.set(mark(curr, next), m)
+		marks.set(mark(to, toBack), m)
+		if m == 1 {
+			markedOnce++
+		} else {
+			markedOnce--
+		}
+		curr, back, revisit = to, toBack, visited.Has(int(to))
+		visited.Set(int(to))
+		trace.expand(curr, markedOnce)
+	}
+
+	// the passages marked once lead from start to goal without crossing
+	path := []Node{start}
+	prev := Node(-1)
+	for n := start; n != goal; {
+		edges = g.Neighbors(n, edges)
+		next := Node(-1)
+		for i, edge := range edges {
+			if edge.To != prev && marks.get(mark(n, i)) == 1 {
+				next = edge.To
+				break
+			}
+		}
+		if next < 0 || len(path) > markedOnce {
+			return Result{}, fmt.Errorf("the passages marked once don't lead from %d to %d", start, goal)
+		}
+		prev, n = n, next
+		path = append(path, n)
+	}
+	return Result{Path: path, Cost: pathCost(g, path), Expanded: steps, Elapsed: time.Since(began)}, nil
+}
+
+// deadEndFilling counts the neighbours of every node, then fills the nodes
+// left with a single one, which can take their neighbour down to one, and so on
+func deadEndFilling(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	trace := newTracer(ctx)
+	walled, _ := g.(Walled)
+	filled := grid.NewBitset(g.Len())
+	degrees := make([]int32, g.Len())
+	queue := newNodeQueue(1024)
+	edges := make([]Edge, 0, g.MaxDegree())
+	expanded := 0
+	deadEnd := func(n Node) bool {
+		return degrees[n] <= 1 && n != start && n != goal
+	}
+	for n := Node(0); int(n) < g.Len(); n++ {
+		if int(n)%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		if walled != nil && !walled.Open(n) {
+			filled.Set(int(n))
+			continue
+		}
+		edges = g.Neighbors(n, edges)
+		degrees[n] = int32(len(edges))
+		if deadEnd(n) {
+			queue.push(n)
+		}
+	}
+	for queue.Len() > 0 {
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		n := queue.pop()
+		if filled.Has(int(n)) {
+			continue
+		}
+		filled.Set(int(n))
+		expanded++
+		edges = g.Neighbors(n, edges)
+		for _, edge := range edges {
+			if filled.Has(int(edge.To)) {
+				continue
+			}
+			degrees[edge.To]--
+			if deadEnd(edge.To) {
+				queue.push(edge.To)
+				trace.push(edge.To)
+			}
+		}
+		// the filled nodes are the ones expanded
+		trace.expand(n, queue.Len())
+	}
+
+	path, err := depthFirst(ctx, g, start, goal, func(n Node) bool { return !filled.Has(int(n)) }, &expanded)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Path: path, Cost: pathCost(g, path), Expanded: expanded, Elapsed: time.Since(began)}, nil
+}
+
+// culDeSacFilling keeps the nodes that are on some path from start to goal
+// without going through a node twice, and fills the rest. Those are the
+// biconnected components, found with Tarjan's algorithm from start, between
+// start and goal: everything else hangs off them by a single node.
+func culDeSacFilling(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	if start == goal {
+		return Result{Path: []Node{start}, Elapsed: time.Since(began)}, nil
+	}
+
+	type frame struct {
+		node, parent Node
+		next         int
+	}
+	// discovered is the order nodes were found in starting at 1, 0 for the
+	// ones not found yet, and low the lowest order reachable from the subtree
+	// of a node through a single edge that isn't in the tree
+	trace := newTracer(ctx)
+	discovered := make([]int32, g.Len())
+	low := make([]int32, g.Len())
+	// hasGoal are the nodes with goal in their subtree
+	hasGoal := grid.NewBitset(g.Len())
+	kept := grid.NewBitset(g.Len())
+	order := int32(1)
+	discovered[start], low[start] = order, order
+	stack := []frame{{node: start, parent: -1}}
+	component := []Node{start}
+	edges := make([]Edge, 0, g.MaxDegree())
+	expanded := 0
+	for len(stack) > 0 {
+		if expanded%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		top := &stack[len(stack)-1]
+		edges = g.Neighbors(top.node, edges)
+		if top.next < len(edges) {
+			to := edges[top.next].To
+			top.next++
+			if discovered[to] == 0 {
+				order++
+				discovered[to], low[to] = order, order
+				if to == goal {
+					hasGoal.Set(int(to))
+				}
+				component = append(component, to)
+				stack = append(stack, frame{node: to, parent: top.node})
+				expanded++
+				trace.expand(to, len(stack))
+			} else if to != top.parent {
+				low[top.node] = min(low[top.node], discovered[to])
+			}
+			continue
+		}
+
+		child := *top
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			break
+		}
+		parent := child.parent
+		low[parent] = min(low[parent], low[child.node])
+		if hasGoal.Has(int(child.node)) {
+			hasGoal.Set(int(parent))
+		}
+		if low[child.node] < discovered[parent] {
+			continue
+		}
+		// parent separates the component of child from the rest, it's
+		// between start and goal when goal is beyond it
+		between := hasGoal.Has(int(child.node))
+		for {
+			n := component[len(component)-1]
+			component = component[:len(component)-1]
+			if between {
+				kept.Set(int(n))
+			}
+			if n == child.node {
+				break
+			}
+		}
+		if between {
+			kept.Set(int(parent))
+		}
+	}
+	if discovered[goal] == 0 {
+		return Result{}, ErrNoPath
+	}
+
+	path, err := depthFirst(ctx, g, start, goal, func(n Node) bool { return kept.Has(int(n)) }, &expanded)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Path: path, Cost: pathCost(g, path), Expanded: expanded, Elapsed: time.Since(began)}, nil
+}
+
+// compass are the directions turning right, so turning right from compass[i]
+// is compass[i+1] and turning left compass[i+3]
+var compass = [4]image.Point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+
+// trail is the path of a walker with the loops it walked around taken out.
+// Every step is traced as the node walked to being expanded.
+type trail struct {
+	path  []Node
+	on    grid.Bitset
+	trace *tracer
+}
+
+func newTrail(ctx context.Context, g Graph, start Node) *trail {
+	t := &trail{path: []Node{start}, on: grid.NewBitset(g.Len()), trace: newTracer(ctx)}
+	t.on.Set(int(start))
+	return t
+}
+
+func (t *trail) step(n Node) {
+	if !t.on.Has(int(n)) {
+		t.on.Set(int(n))
+		t.path = append(t.path, n)
+	}
+	for t.path[len(t.path)-1] != n {
+		t.on.Clear(int(t.path[len(t.path)-1]))
+		t.path = t.path[:len(t.path)-1]
+	}
+	t.trace.expand(n, len(t.path))
+}
+
+// fourConnectedGrid returns g as a GridGraph, if it's a FourConnected one
+func fourConnectedGrid(g Graph) (GridGraph, error) {
+	gg, ok := g.(GridGraph)
+	if !ok || gg.Connectivity != FourConnected {
+		return GridGraph{}, ErrUnsupportedGraph
+	}
+	return gg, nil
+}
+
+// wallFollower gives up once it's back where it was facing the same way
+func wallFollower(right bool) SolverFunc {
+	// the turns to try, in order, as steps around the compass
+	turns := [4]int{3, 0, 1, 2}
+	if right {
+		turns = [4]int{1, 0, 3, 2}
+	}
+	return func(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+		began := time.Now()
+		if err := checkEndpoints(g, start, goal); err != nil {
+			return Result{}, err
+		}
+		gg, err := fourConnectedGrid(g)
+		if err != nil {
+			return Result{}, err
+		}
+
+		// a bit for every pixel and way it can be facing
+		been := grid.NewBitset(4 * g.Len())
+		t := newTrail(ctx, g, start)
+		p, heading := gg.Point(start), 0
+		steps := 0
+		for ; gg.Node(p) != goal; steps++ {
+			if steps%ctxCheckInterval == 0 && ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			state := 4*int(gg.Node(p)) + heading
+			if been.Has(state) {
+				return Result{}, ErrNoPath
+			}
+			been.Set(state)
+
+			moved := false
+			for _, turn := range turns {
+				d := (heading + turn) % 4
+				if next := p.Add(compass[d]); gg.Grid.Open(next.X, next.Y) {
+					p, heading, moved = next, d, true
+					break
+				}
+			}
+			if !moved {
+				return Result{}, ErrNoPath
+			}
+			t.step(gg.Node(p))
+		}
+		return Result{Path: t.path, Cost: float64(len(t.path) - 1), Expanded: steps, Elapsed: time.Since(began)}, nil
+	}
+}
+
+// pledge follows walls with its right hand. It gives up after walking past
+// every pixel facing every way 4 times, it can't tell it's going in circles
+// from the turns it has to undo.
+func pledge(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	gg, err := fourConnectedGrid(g)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// the way to go is the one that gets closest to goal
+	p, to := gg.Point(start), gg.Point(goal)
+	preferred := 0
+	for i, d := range compass {
+		if Manhattan(p.Add(d), to) < Manhattan(p.Add(compass[preferred]), to) {
+			preferred = i
+		}
+	}
+
+	t := newTrail(ctx, g, start)
+	// turns adds up right turns as 1 and left ones as -1
+	heading, turns := preferred, 0
+	steps := 0
+	for ; gg.Node(p) != goal; steps++ {
+		if steps%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		if steps > 16*g.Len() {
+			return Result{}, ErrNoPath
+		}
+
+		if turns == 0 {
+			if next := p.Add(compass[heading]); gg.Grid.Open(next.X, next.Y) {
+				p = next
+				t.step(gg.Node(p))
+				continue
+			}
+			// turn left to put the wall on the right
+			heading, turns = (heading+3)%4, -1
+		}
+		moved := false
+		for _, turn := range []int{1, 0, -1, -2} {
+			d := (heading + turn + 4) % 4
+			if next := p.Add(compass[d]); gg.Grid.Open(next.X, next.Y) {
+				p, heading, turns, moved = next, d, turns+turn, true
+				break
+			}
+		}
+		if !moved {
+			return Result{}, ErrNoPath
+		}
+		t.step(gg.Node(p))
+	}
+	return Result{Path: t.path, Cost: float64(len(t.path) - 1), Expanded: steps, Elapsed: time.Since(began)}, nil
+}
+
+// shortestPathsFinder walks back from goal through neighbours whose distance
+// from start is the one of the node it's on minus the step between them
+func shortestPathsFinder(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	began := time.Now()
+	if err := checkEndpoints(g, start, goal); err != nil {
+		return Result{}, err
+	}
+	distances, err := DistanceField(ctx, g, start)
+	if err != nil {
+		return Result{}, err
+	}
+	if math.IsInf(distances[goal], 1) {
+		return Result{}, ErrNoPath
+	}
+	expanded := 0
+	for _, d := range distances {
+		if !math.IsInf(d, 1) {
+			expanded++
+		}
+	}
+
+	path := []Node{goal}
+	edges := make([]Edge, 0, g.MaxDegree())
+	back := make([]Edge, 0, g.MaxDegree())
+	for curr := goal; curr != start; {
+		edges = g.Neighbors(curr, edges)
+		found := false
+		for _, edge := range edges {
+			var i int
+			back, i, err = reverseEdge(g, curr, edge.To, back)
+			if err != nil {
+				return Result{}, err
+			}
+			// the distances were added up the same way, so they're equal
+			// to the last bit on a shortest path
+			if distances[edge.To]+back[i].Cost == distances[curr] {
+				curr, found = edge.To, true
+				break
+			}
+		}
+		if !found {
+			return Result{}, fmt.Errorf("no neighbour of %d is on a shortest path to it", curr)
+		}
+		path = append(path, curr)
+	}
+	slices.Reverse(path)
+	return Result{Path: path, Cost: distances[goal], Expanded: expanded, Elapsed: time.Since(began)}, nil
+}
@@ -0,0 +1,148 @@
+package path
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math/rand"
+	"mazes/grid"
+	"testing"
+)
+
+// classic are the solvers of mazesolvers.go
+var classic = []string{"dead-end-filling", "cul-de-sac-filling", "wall-follower-left", "wall-follower-right", "pledge", "tremaux", "shortest-paths-finder"}
+
+func TestClassicSolversOnPerfectMazes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		cols, rows := 1+r.Intn(30), 1+r.Intn(30)
+		g := NewGridGraph(randomBraidedMaze(r, cols, rows, 0))
+		start := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+		end := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+		expected := bfsDistance(g.Grid, start, end)
+
+		// there's a single path between any two cells, and the walls are all
+		// connected so following them gets everywhere
+		for _, name := range classic {
+			solver, _ := Lookup(name)
+			res, err := solver.Solve(context.Background(), g, g.Node(start), g.Node(end))
+			if err != nil {
+				t.Fatalf("%s on maze %d: expected no error, but got: %v", name, i, err)
+			}
+			checkPath(t, g, res.Path, start, end)
+			if len(res.Path)-1 != expected || res.Cost != float64(expected) {
+				t.Fatalf("%s on maze %d: expected a path of length %d from %v to %v, but got: %d", name, i, expected, start, end, len(res.Path)-1)
+			}
+		}
+	}
+}
+
+func TestClassicSolversOnBraidedMazes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		cols, rows := 1+r.Intn(30), 1+r.Intn(30)
+		g := NewGridGraph(randomBraidedMaze(r, cols, rows, r.Float64()))
+		start := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+		end := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+
+		// the wall followers can walk around a loop of walls forever
+		for _, name := range []string{"dead-end-filling", "cul-de-sac-filling", "tremaux"} {
+			solver, _ := Lookup(name)
+			res, err := solver.Solve(context.Background(), g, g.Node(start), g.Node(end))
+			if err != nil {
+				t.Fatalf("%s on maze %d: expected no error, but got: %v", name, i, err)
+			}
+			checkPath(t, g, res.Path, start, end)
+		}
+	}
+}
+
+func TestTremauxWalksPassagesAtMostTwice(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		cols, rows := 1+r.Intn(30), 1+r.Intn(30)
+		g := NewGridGraph(randomBraidedMaze(r, cols, rows, r.Float64()))
+		passages := 0
+		var edges []Edge
+		for n := Node(0); int(n) < g.Len(); n++ {
+			edges = g.Neighbors(n, edges)
+			passages += len(edges)
+		}
+		passages /= 2
+		start := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+		end := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+
+		res, err := Tremaux.Solve(context.Background(), g, g.Node(start), g.Node(end))
+		if err != nil {
+			t.Fatalf("maze %d: expected no error, but got: %v", i, err)
+		}
+		if res.Expanded > 2*passages {
+			t.Fatalf("maze %d: expected at most %d steps, but got: %d", i, 2*passages, res.Expanded)
+		}
+	}
+
+	// goal is on another triangle, there are loops to walk around before giving up
+	g := NewAdjacencyList(6)
+	for _, tri := range [][3]Node{{0, 1, 2}, {3, 4, 5}} {
+		g.AddEdge(tri[0], tri[1], 1)
+		g.AddEdge(tri[1], tri[2], 1)
+		g.AddEdge(tri[2], tri[0], 1)
+	}
+	if _, err := Tremaux.Solve(context.Background(), g, 0, 4); !errors.Is(err, ErrNoPath) {
+		t.Fatalf("Expected %v, but got: %v", ErrNoPath, err)
+	}
+}
+
+func TestCulDeSacFilling(t *testing.T) {
+	// a corridor from 0 to 5 with a loop hanging off 2 by a single node
+	// and another one between 3 and 4
+	g := NewAdjacencyList(10)
+	for _, e := range [][2]Node{{0, 1}, {1, 2}, {2, 6}, {6, 7}, {7, 8}, {8, 6}, {2, 3}, {3, 4}, {4, 5}, {3, 9}, {9, 4}} {
+		g.AddEdge(e[0], e[1], 1)
+	}
+	for _, goal := range []Node{5, 8} {
+		for _, name := range []string{"cul-de-sac-filling", "dead-end-filling"} {
+			solver, _ := Lookup(name)
+			res, err := solver.Solve(context.Background(), g, 0, goal)
+			if err != nil {
+				t.Fatalf("%s: expected no error, but got: %v", name, err)
+			}
+			checkGraphPath(t, g, res.Path, 0, goal)
+		}
+	}
+
+}
+
+func TestGridOnlySolvers(t *testing.T) {
+	cells := NewCellGraph(grid.Lattice{CellSize: 1, WallThickness: 1, Cols: 3, Rows: 3}.Cells(randomBraidedMaze(rand.New(rand.NewSource(1)), 3, 3, 0)))
+	diagonal := openGrid(5, 5)
+	diagonal.Connectivity = EightConnected
+	for _, name := range []string{"wall-follower-left", "wall-follower-right", "pledge"} {
+		solver, _ := Lookup(name)
+		for _, g := range []Graph{cells, diagonal} {
+			if _, err := solver.Solve(context.Background(), g, 0, 1); !errors.Is(err, ErrUnsupportedGraph) {
+				t.Fatalf("%s: expected %v, but got: %v", name, ErrUnsupportedGraph, err)
+			}
+		}
+	}
+}
+
+func TestGuarantees(t *testing.T) {
+	expected := map[string]Guarantee{
+		"bfs":                   FewestEdges,
+		"astar":                 Cheapest,
+		"dead-end-filling":      AnyPath,
+		"pledge":                AnyPath,
+		"tremaux":               AnyPath,
+		"shortest-paths-finder": Cheapest,
+	}
+	for name, guarantee := range expected {
+		solver, _ := Lookup(name)
+		if got := GuaranteeOf(solver); got != guarantee {
+			t.Fatalf("%s: expected %v, but got: %v", name, guarantee, got)
+		}
+	}
+	if got := GuaranteeOf(SolverFunc(tremaux)); got != AnyPath {
+		t.Fatalf("Expected %v, but got: %v", AnyPath, got)
+	}
+}
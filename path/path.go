@@ -1,254 +1,166 @@
 package path
 
 import (
-	"math"
+	"errors"
+	"fmt"
+	"math/bits"
 	"slices"
 )
 
-type Node struct {
-	x, y     int
-	children []*Node
-}
+// Node identifies a node of a Graph, they're numbered from 0 to Len()-1
+type Node int
 
-func getOrNil(nodes [][]*Node, x, y int) *Node {
-	if y < 0 || y >= len(nodes) || x < 0 || x >= len(nodes[y]) {
-		return nil
-	}
-	return nodes[y][x]
+type Edge struct {
+	To   Node
+	Cost float64
 }
 
-func getOrNilMaze(maze [][]bool, y, x int) (Node2, bool) {
-	if y < 0 || y >= len(maze) || x < 0 || x >= len(maze[y]) || maze[y][x] == false {
-		return Node2{}, false
-	}
-	return Node2{x, y}, true
+// Graph is what solvers search. Every edge has to have one going back, maybe
+// with another cost, solvers rely on them to walk paths back from the goal.
+type Graph interface {
+	Len() int
+	// MaxDegree is the most edges a node can have, solvers size their per node state with it
+	MaxDegree() int
+	// Neighbors appends the edges leaving n to buf[:0], always in the same order
+	Neighbors(n Node, buf []Edge) []Edge
 }
 
-func makeTreeFromMaze(maze [][]bool, startX, startY int) *Node {
-	nodes := make([][]*Node, len(maze))
-	for y, row := range maze {
-		nodes[y] = make([]*Node, len(maze[y]))
-
-		for x, wall := range row {
-			if !wall {
-				nodes[y][x] = &Node{x: x, y: y}
-			}
-		}
-	}
-	for y := range nodes {
-		for x, node := range nodes[y] {
-			if node != nil {
-				if child := getOrNil(nodes, y-1, x-1); child != nil {
-					node.children = append(node.children, child)
-				}
-				if child := getOrNil(nodes, y-1, x); child != nil {
-					node.children = append(node.children, child)
-				}
-				if child := getOrNil(nodes, y-1, x+1); child != nil {
-					node.children = append(node.children, child)
-				}
-				if child := getOrNil(nodes, y, x-1); child != nil {
-					node.children = append(node.children, child)
-				}
-				if child := getOrNil(nodes, y, x+1); child != nil {
-					node.children = append(node.children, child)
-				}
-				if child := getOrNil(nodes, y+1, x-1); child != nil {
-					node.children = append(node.children, child)
-				}
-				if child := getOrNil(nodes, y+1, x); child != nil {
-					node.children = append(node.children, child)
-				}
-				if child := getOrNil(nodes, y+1, x+1); child != nil {
-					node.children = append(node.children, child)
-				}
-			}
-		}
-	}
-
-	return nodes[startY][startX]
+// Estimator is implemented by graphs that know how far apart their nodes are.
+// Estimates can't be more than the real cost and can't drop by more than the
+// cost of an edge when following it, otherwise A* misses shortest paths.
+type Estimator interface {
+	Estimate(from, to Node) float64
 }
 
-func getChildNodes(maze [][]bool, node Node2) []Node2 {
-	x := node.X
-	y := node.Y
-	nodes := make([]Node2, 0)
-
-	//if child, ok := getOrNilMaze(maze, y-1, x-1); ok {
-	//	nodes = append(nodes, child)
-	//}
-	if child, ok := getOrNilMaze(maze, y-1, x); ok {
-		nodes = append(nodes, child)
-	}
-	//if child, ok := getOrNilMaze(maze, y-1, x+1); ok {
-	//	nodes = append(nodes, child)
-	//}
-	if child, ok := getOrNilMaze(maze, y, x-1); ok {
-		nodes = append(nodes, child)
-	}
-	if child, ok := getOrNilMaze(maze, y, x+1); ok {
-		nodes = append(nodes, child)
-	}
-	//if child, ok := getOrNilMaze(maze, y+1, x-1); ok {
-	//	nodes = append(nodes, child)
-	//}
-	if child, ok := getOrNilMaze(maze, y+1, x); ok {
-		nodes = append(nodes, child)
-	}
-	//if child, ok := getOrNilMaze(maze, y+1, x+1); ok {
-	//	nodes = append(nodes, child)
-	//}
-
-	return nodes
+// Walled is implemented by graphs with nodes that can't be walked on, like
+// the walls of a pixel grid
+type Walled interface {
+	Open(n Node) bool
 }
 
-//func BruteForce(maze [][]bool, startX, startY, endX, endY int) {
-//	tree := makeTreeFromMaze(maze, startX, startY)
-//
-//	visited := make([][]bool, len(maze))
-//	for y := range maze {
-//		visited[y] = make([]bool, len(maze[y]))
-//	}
-//
-//	queue := make([]*Node, 0)
-//	for len(queue) > 0 && queue[0] != nil {
-//		node := queue[0]
-//
-//		if node.x == endX && node.y == endY {
-//
-//		}
-//	}
-//}
+// Symmetric is implemented by graphs that know whether their edges cost the
+// same both ways. Solvers searching backward then take the cost of an edge as
+// it is instead of looking for the one going back.
+type Symmetric interface {
+	Symmetric() bool
+}
 
-func BruteForceFind(node *Node, visited *[][]bool, endX, endY int) {
-	queue := make([]*Node, 0)
-	for len(queue) > 0 && queue[0] != nil {
-		node := queue[0]
+// Heuristic estimates the cost from a node to another, with the same rules as Estimator
+type Heuristic func(from, to Node) float64
 
-		if node.x == endX && node.y == endY {
+type heuristicGraph struct {
+	Graph
+	h Heuristic
+}
 
-		}
-	}
+func (g heuristicGraph) Estimate(from, to Node) float64 {
+	return g.h(from, to)
 }
 
-type DijkstraNode struct {
-	*Node
+func (g heuristicGraph) Open(n Node) bool {
+	walled, ok := g.Graph.(Walled)
+	return !ok || walled.Open(n)
+}
 
-	distance int
-	pathVia  *Node
+func (g heuristicGraph) Symmetric() bool {
+	symmetric, ok := g.Graph.(Symmetric)
+	return ok && symmetric.Symmetric()
 }
 
-func InsertOrdered(slice []DijkstraNode, el DijkstraNode) []DijkstraNode {
-	index := len(slice) - 1
-	for i, node := range slice {
-		if node.distance > el.distance {
-			index = i
-		}
-	}
-	return slices.Insert(slice, index, el)
+// WithHeuristic makes A* on g use h instead of the estimates g has, if any
+func WithHeuristic(g Graph, h Heuristic) Graph {
+	return heuristicGraph{Graph: g, h: h}
 }
 
-func InsertOrdered1(slice []*DNode, el *DNode) []*DNode {
-	index := len(slice)
-	for i, node := range slice {
-		if float64(node.distance)+node.distanceRemaining > float64(el.distance)+el.distanceRemaining {
-			index = i
+var (
+	ErrNoPath       = errors.New("no path between start and end")
+	ErrStartBlocked = errors.New("start is a wall")
+	ErrGoalBlocked  = errors.New("end is a wall")
+	ErrOutOfBounds  = errors.New("node is outside of the graph")
+	// ErrUnsupportedGraph is returned by solvers that only work on some kinds of graphs
+	ErrUnsupportedGraph = errors.New("graph not supported by the solver")
+)
+
+// checkEndpoints returns an error wrapping ErrOutOfBounds, ErrStartBlocked or
+// ErrGoalBlocked when start or goal can't be part of a path
+func checkEndpoints(g Graph, start, goal Node) error {
+	for _, n := range []Node{start, goal} {
+		if n < 0 || int(n) >= g.Len() {
+			return fmt.Errorf("%w: %d in a graph of %d nodes", ErrOutOfBounds, n, g.Len())
 		}
 	}
-	return slices.Insert(slice, index, el)
-}
-
-func UpdateOrder1(slice []*DNode, el *DNode) []*DNode {
-	oldIndex := slices.Index(slice, el)
-	slice = slices.Delete(slice, oldIndex, oldIndex)
-	return InsertOrdered1(slice, el)
+	walled, ok := g.(Walled)
+	if !ok {
+		return nil
+	}
+	if !walled.Open(start) {
+		return fmt.Errorf("%w: %d", ErrStartBlocked, start)
+	}
+	if !walled.Open(goal) {
+		return fmt.Errorf("%w: %d", ErrGoalBlocked, goal)
+	}
+	return nil
 }
 
-type Node2 struct {
-	X, Y int
+// parents holds, for every node, the index of the edge going back to the node
+// that reached it, in as few bits as the degree of the graph needs. That's 2
+// bits per pixel for a 4-connected grid.
+type parents struct {
+	bits    int
+	perWord int
+	data    []uint64
 }
-type DNode struct {
-	Node2
 
-	distance          int
-	pathVia           *DNode
-	distanceRemaining float64
+func newParents(size, maxDegree int) parents {
+	width := max(bits.Len(uint(maxDegree-1)), 1)
+	perWord := 64 / width
+	return parents{bits: width, perWord: perWord, data: make([]uint64, (size+perWord-1)/perWord)}
 }
 
-func Dijkstra(maze [][]bool, startX, startY, endX, endY int) []Node2 {
-	return dijkstraOrAStar(false, maze, startX, startY, endX, endY)
+func (p parents) set(n Node, edge int) {
+	shift := int(n) % p.perWord * p.bits
+	mask := uint64(1)<<p.bits - 1
+	p.data[int(n)/p.perWord] = p.data[int(n)/p.perWord]&^(mask<<shift) | uint64(edge)<<shift
 }
 
-func Astart(maze [][]bool, startX, startY, endX, endY int) []Node2 {
-	return dijkstraOrAStar(true, maze, startX, startY, endX, endY)
+func (p parents) get(n Node) int {
+	shift := int(n) % p.perWord * p.bits
+	return int(p.data[int(n)/p.perWord]>>shift) & (1<<p.bits - 1)
 }
 
-func dijkstraOrAStar(astar bool, maze [][]bool, startX, startY, endX, endY int) []Node2 {
-	nodes := make([][]*DNode, len(maze))
-	for y := range nodes {
-		nodes[y] = make([]*DNode, len(maze[y]))
+// setParent stores that to was reached from from, buf is used for the edges of to
+func (p parents) setParent(g Graph, from, to Node, buf []Edge) ([]Edge, error) {
+	buf, back, err := reverseEdge(g, from, to, buf)
+	if err == nil {
+		p.set(to, back)
 	}
+	return buf, err
+}
 
-	startNode := &DNode{Node2: Node2{startX, startY}, distance: 0, pathVia: nil}
-	nodes[startY][startX] = startNode
-
-	finished := make([]*DNode, 0)
-
-	pqueue := make([]*DNode, 0)
-	pqueue = append(pqueue, startNode)
-
-	for {
-		top := pqueue[0]
-		pqueue = pqueue[1:]
-		if top.X == endX && top.Y == endY {
-			// todo
-			pqueue = append(pqueue, top)
-
-			solution := make([]Node2, 0)
-
-			currNode := top
-			for currNode != nil {
-				solution = append(solution, currNode.Node2)
-				currNode = currNode.pathVia
-			}
-			slices.Reverse(solution)
-			return solution
-		}
-
-		children := getChildNodes(maze, top.Node2)
-		for _, child := range children {
-			childNode := nodes[child.Y][child.X]
-			if childNode != nil {
-				if childNode == top.pathVia {
-					continue
-				}
-				newDistance := childNode.distance + AbsInt(child.X-top.X) + AbsInt(child.Y-top.Y)
-				if newDistance >= childNode.distance {
-					continue
-				}
-
-				childNode.distance = newDistance
-				childNode.pathVia = top
-				pqueue = UpdateOrder1(pqueue, childNode)
-			} else {
-				childNode = &DNode{Node2: child}
-				childNode.distance = AbsInt(child.X-top.X) + AbsInt(child.Y-top.Y)
-				childNode.pathVia = top
-				if astar {
-					distX := float64(child.X - endX)
-					distY := float64(child.Y - endY)
-					dist := math.Sqrt(distX*distX + distY*distY)
-
-					childNode.distanceRemaining = dist
-				}
-
-				nodes[child.Y][child.X] = childNode
-				pqueue = InsertOrdered1(pqueue, childNode)
-			}
+// reverseEdge puts the edges of to in buf and finds the one going back to from
+func reverseEdge(g Graph, from, to Node, buf []Edge) ([]Edge, int, error) {
+	buf = g.Neighbors(to, buf)
+	for i, edge := range buf {
+		if edge.To == from {
+			return buf, i, nil
 		}
-
-		finished = append(finished, top)
 	}
+	return buf, 0, fmt.Errorf("there's an edge from %d to %d but not one going back", from, to)
+}
+
+// walkBack follows the parents from goal back to start and returns the path between them
+func (p parents) walkBack(g Graph, start, goal Node) []Node {
+	path := make([]Node, 0)
+	buf := make([]Edge, 0, g.MaxDegree())
+	curr := goal
+	for curr != start {
+		path = append(path, curr)
+		buf = g.Neighbors(curr, buf)
+		curr = buf[p.get(curr)].To
+	}
+	path = append(path, curr)
+	slices.Reverse(path)
+	return path
 }
 
 func AbsInt(a int) int {
@@ -0,0 +1,402 @@
+package path
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"mazes/grid"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readMaze(t testing.TB, name string) *grid.Grid {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	g, err := grid.FromReader(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	return g
+}
+
+// checkPath fails unless path goes from start to end through open neighbouring pixels
+func checkPath(t testing.TB, g GridGraph, path []Node, start, end image.Point) {
+	t.Helper()
+	if len(path) == 0 || g.Point(path[0]) != start || g.Point(path[len(path)-1]) != end {
+		t.Fatalf("Expected a path from %v to %v, but got: %v", start, end, path)
+	}
+	for i, node := range path {
+		p := g.Point(node)
+		if !g.Grid.Open(p.X, p.Y) {
+			t.Fatalf("Expected %v to be open", p)
+		}
+		if i > 0 {
+			if d := p.Sub(g.Point(path[i-1])); AbsInt(d.X)+AbsInt(d.Y) != 1 {
+				t.Fatalf("Expected %v to be next to %v", p, g.Point(path[i-1]))
+			}
+		}
+	}
+}
+
+func TestSolveSampleMazes(t *testing.T) {
+	for _, name := range []string{"../mazediag21x21.png", "../mazediag201x201.png", "../maze (1).png"} {
+		g := NewGridGraph(readMaze(t, name))
+		start, end := image.Pt(0, 1), image.Pt(g.Grid.Width-1, g.Grid.Height-2)
+		for _, name := range Names() {
+			solver, _ := Lookup(name)
+			res, err := solver.Solve(context.Background(), g, g.Node(start), g.Node(end))
+			if err != nil {
+				t.Fatalf("%s: expected no error, but got: %v", name, err)
+			}
+			checkPath(t, g, res.Path, start, end)
+			if res.Cost != float64(len(res.Path)-1) {
+				t.Fatalf("%s: expected a cost of %d, but got: %v", name, len(res.Path)-1, res.Cost)
+			}
+		}
+	}
+}
+
+func TestParents(t *testing.T) {
+	for _, degree := range []int{1, 2, 4, 5, 8, 100} {
+		p := newParents(100, degree)
+		for i := Node(0); i < 100; i++ {
+			p.set(i, int(i)*7%degree)
+		}
+		p.set(33, degree-1)
+		for i := Node(0); i < 100; i++ {
+			expected := int(i) * 7 % degree
+			if i == 33 {
+				expected = degree - 1
+			}
+			if got := p.get(i); got != expected {
+				t.Fatalf("%d with a degree of %d expected edge %d, but got: %d", i, degree, expected, got)
+			}
+		}
+	}
+}
+
+// the sample mazes from small to big, the bigger ones show how solvers scale
+var benchmarkMazes = []string{"mazediag201x201.png", "maze2001x2001.png", "mazediag4001x4001.png"}
+
+func benchmarkSolver(b *testing.B, solver Solver) {
+	benchmarkAgainst(b, solver, nil)
+}
+
+// benchmarkAgainst also reports how many nodes solver expands for every node
+// oneWay expands, below 1 when it does less work
+func benchmarkAgainst(b *testing.B, solver, oneWay Solver) {
+	for _, name := range benchmarkMazes {
+		b.Run(name, func(b *testing.B) {
+			g := readMaze(b, "../"+name)
+			start, end, err := grid.FindEndpoints(g, grid.EndpointOptions{})
+			if err != nil {
+				b.Fatalf("Expected no error, but got: %v", err)
+			}
+			graph := NewGridGraph(g)
+			b.ResetTimer()
+			var res Result
+			for i := 0; i < b.N; i++ {
+				res, _ = solver.Solve(context.Background(), graph, graph.Node(start), graph.Node(end))
+			}
+			b.ReportMetric(float64(res.Expanded), "expanded/op")
+			if oneWay != nil {
+				b.StopTimer()
+				base, _ := oneWay.Solve(context.Background(), graph, graph.Node(start), graph.Node(end))
+				b.ReportMetric(float64(res.Expanded)/float64(base.Expanded), "expanded/one-way")
+			}
+		})
+	}
+}
+
+// openGrid has no walls at all, so the queue grows with the size of the grid
+// instead of staying as small as in a perfect maze
+func openGrid(width, height int) GridGraph {
+	g := grid.New(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			g.SetOpen(x, y, true)
+		}
+	}
+	return NewGridGraph(g)
+}
+
+func BenchmarkOpenGrid(b *testing.B) {
+	for _, size := range []int{101, 301, 601} {
+		b.Run(fmt.Sprintf("%dx%d", size, size), func(b *testing.B) {
+			g := openGrid(size, size)
+			for i := 0; i < b.N; i++ {
+				Dijkstra.Solve(context.Background(), g, g.Node(image.Pt(0, 0)), g.Node(image.Pt(size-1, size-1)))
+			}
+		})
+	}
+}
+
+func BenchmarkDijkstra(b *testing.B) {
+	benchmarkSolver(b, Dijkstra)
+}
+
+func BenchmarkAStar(b *testing.B) {
+	benchmarkSolver(b, AStar)
+}
+
+func TestNodeHeapDecrease(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h := make(nodeHeap, 0)
+	nodes := make([]*DNode, 200)
+	for i := range nodes {
+		nodes[i] = &DNode{distance: float64(r.Intn(1000)), distanceRemaining: r.Float64()}
+		h.push(nodes[i])
+	}
+	for _, node := range nodes[:50] {
+		node.distance -= float64(r.Intn(500))
+		h.decreased(node)
+	}
+
+	last := math.Inf(-1)
+	for h.Len() > 0 {
+		node := h.pop()
+		if node.priority() < last {
+			t.Fatalf("Expected nodes in increasing priority, but got: %v after %v", node.priority(), last)
+		}
+		last = node.priority()
+	}
+}
+
+// randomBraidedMaze carves a perfect maze of cols x rows cells with a
+// randomized depth first search, then knocks down each remaining wall between
+// two cells with probability braid, which adds loops
+func randomBraidedMaze(r *rand.Rand, cols, rows int, braid float64) *grid.Grid {
+	g := grid.New(2*cols+1, 2*rows+1)
+	visited := make([]bool, cols*rows)
+	stack := []image.Point{{0, 0}}
+	visited[0] = true
+	g.SetOpen(1, 1, true)
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		next := make([]image.Point, 0, 4)
+		for _, step := range steps {
+			n := c.Add(step)
+			if n.X >= 0 && n.Y >= 0 && n.X < cols && n.Y < rows && !visited[n.Y*cols+n.X] {
+				next = append(next, n)
+			}
+		}
+		if len(next) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		n := next[r.Intn(len(next))]
+		visited[n.Y*cols+n.X] = true
+		g.SetOpen(2*n.X+1, 2*n.Y+1, true)
+		g.SetOpen(c.X+n.X+1, c.Y+n.Y+1, true)
+		stack = append(stack, n)
+	}
+
+	// walls between cells have one odd and one even coordinate
+	for y := 1; y < g.Height-1; y++ {
+		for x := 1; x < g.Width-1; x++ {
+			if (x+y)%2 == 1 && r.Float64() < braid {
+				g.SetOpen(x, y, true)
+			}
+		}
+	}
+	return g
+}
+
+// bfsDistance is the length of the shortest path from start to end, -1 if there's none
+func bfsDistance(g *grid.Grid, start, end image.Point) int {
+	distances := make([]int, g.Width*g.Height)
+	for i := range distances {
+		distances[i] = -1
+	}
+	distances[g.Index(start.X, start.Y)] = 0
+	queue := []image.Point{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, step := range steps {
+			n := node.Add(step)
+			if g.Open(n.X, n.Y) && distances[g.Index(n.X, n.Y)] == -1 {
+				distances[g.Index(n.X, n.Y)] = distances[g.Index(node.X, node.Y)] + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return distances[g.Index(end.X, end.Y)]
+}
+
+func TestShortestPathsOnBraidedMazes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		cols, rows := 1+r.Intn(30), 1+r.Intn(30)
+		g := randomBraidedMaze(r, cols, rows, r.Float64()*0.5)
+		start := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+		end := image.Pt(2*r.Intn(cols)+1, 2*r.Intn(rows)+1)
+		expected := bfsDistance(g, start, end)
+
+		graph := NewGridGraph(g)
+		for _, name := range guaranteeing(FewestEdges) {
+			solver, _ := Lookup(name)
+			res, err := solver.Solve(context.Background(), graph, graph.Node(start), graph.Node(end))
+			if err != nil {
+				t.Fatalf("%s on maze %d: expected no error, but got: %v", name, i, err)
+			}
+			checkPath(t, graph, res.Path, start, end)
+			if len(res.Path)-1 != expected {
+				t.Fatalf("%s on maze %d: expected a path of length %d from %v to %v, but got: %d", name, i, expected, start, end, len(res.Path)-1)
+			}
+		}
+	}
+}
+
+// guaranteeing are the names of the solvers guaranteeing at least guarantee
+func guaranteeing(guarantee Guarantee) []string {
+	var names []string
+	for _, name := range Names() {
+		if solver, _ := Lookup(name); GuaranteeOf(solver) >= guarantee {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestSolversAreDeterministic(t *testing.T) {
+	g := openGrid(40, 30)
+	start, end := g.Node(image.Pt(3, 2)), g.Node(image.Pt(35, 27))
+	expected, _ := AStar.Solve(context.Background(), g, start, end)
+	for i := 0; i < 10; i++ {
+		if got, _ := AStar.Solve(context.Background(), g, start, end); !reflect.DeepEqual(got.Path, expected.Path) {
+			t.Fatalf("Expected the same path every time, but got: %v and %v", expected, got)
+		}
+	}
+}
+
+func TestSolverErrors(t *testing.T) {
+	g := openGrid(5, 5)
+	for x := 0; x < 5; x++ {
+		g.Grid.SetOpen(x, 2, false)
+	}
+	tests := []struct {
+		name       string
+		start, end image.Point
+		expected   error
+	}{
+		{"unreachable", image.Pt(0, 0), image.Pt(4, 4), ErrNoPath},
+		{"start on a wall", image.Pt(1, 2), image.Pt(4, 4), ErrStartBlocked},
+		{"end on a wall", image.Pt(0, 0), image.Pt(3, 2), ErrGoalBlocked},
+		{"start outside", image.Pt(-1, 0), image.Pt(4, 4), ErrOutOfBounds},
+		{"end outside", image.Pt(0, 0), image.Pt(4, 5), ErrOutOfBounds},
+	}
+	for _, test := range tests {
+		for _, name := range Names() {
+			solver, _ := Lookup(name)
+			res, err := solver.Solve(context.Background(), g, g.Node(test.start), g.Node(test.end))
+			if !errors.Is(err, test.expected) || res.Path != nil {
+				t.Fatalf("%s %s: expected %v and no path, but got: %v and %v", name, test.name, test.expected, err, res.Path)
+			}
+		}
+	}
+
+	for _, name := range Names() {
+		solver, _ := Lookup(name)
+		res, err := solver.Solve(context.Background(), g, g.Node(image.Pt(1, 1)), g.Node(image.Pt(1, 1)))
+		if err != nil || len(res.Path) != 1 || res.Cost != 0 {
+			t.Fatalf("%s: expected a single node path from a point to itself, but got: %v, %v", name, res.Path, err)
+		}
+	}
+}
+
+func TestSolverCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g := openGrid(100, 100)
+	for _, name := range Names() {
+		solver, _ := Lookup(name)
+		if _, err := solver.Solve(ctx, g, g.Node(image.Pt(0, 0)), g.Node(image.Pt(99, 99))); !errors.Is(err, context.Canceled) {
+			t.Fatalf("%s: expected %v, but got: %v", name, context.Canceled, err)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"astar", "dijkstra"} {
+		if _, ok := Lookup(name); !ok {
+			t.Fatalf("Expected %s to be registered", name)
+		}
+	}
+	if _, ok := Lookup("nope"); ok {
+		t.Fatalf("Expected an unknown solver to not be found")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected registering a name twice to panic")
+		}
+	}()
+	Register("astar", AStar)
+}
+
+func BenchmarkBFS(b *testing.B) {
+	benchmarkSolver(b, BFS)
+}
+
+// the bidirectional solvers expand about a quarter fewer nodes on the diagonal
+// mazes. maze2001x2001 is the exception, almost all of it is closer to start
+// than the goal is, so no split of the path between the sides beats BFS there.
+func BenchmarkBidirectionalBFS(b *testing.B) {
+	benchmarkAgainst(b, BidirectionalBFS, BFS)
+}
+
+func BenchmarkBidirectionalDijkstra(b *testing.B) {
+	benchmarkAgainst(b, BidirectionalDijkstra, Dijkstra)
+}
+
+func BenchmarkBidirectionalAStar(b *testing.B) {
+	benchmarkAgainst(b, BidirectionalAStar, AStar)
+}
+
+// BenchmarkBFSLargeMaze solves a 10001x10001 perfect maze corner to corner,
+// which visits nearly every pixel
+func BenchmarkBFSLargeMaze(b *testing.B) {
+	g := NewGridGraph(randomBraidedMaze(rand.New(rand.NewSource(1)), 5000, 5000, 0))
+	start, end := g.Node(image.Pt(1, 1)), g.Node(image.Pt(g.Grid.Width-2, g.Grid.Height-2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := BFS.Solve(context.Background(), g, start, end); err != nil {
+			b.Fatalf("Expected no error, but got: %v", err)
+		}
+	}
+}
+
+func TestNodeQueue(t *testing.T) {
+	q := newNodeQueue(3)
+	next, expected := Node(0), Node(0)
+	// interleaving pushes and pops wraps around the buffer before it grows
+	for round := 1; round < 20; round++ {
+		for i := 0; i < round; i++ {
+			q.push(next)
+			next++
+		}
+		for i := 0; i < round/2; i++ {
+			if got := q.pop(); got != expected {
+				t.Fatalf("Expected %d, but got: %d", expected, got)
+			}
+			expected++
+		}
+	}
+	for q.Len() > 0 {
+		if got := q.pop(); got != expected {
+			t.Fatalf("Expected %d, but got: %d", expected, got)
+		}
+		expected++
+	}
+	if expected != next {
+		t.Fatalf("Expected %d nodes, but got: %d", next, expected)
+	}
+}
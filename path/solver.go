@@ -0,0 +1,95 @@
+package path
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type Solver interface {
+	// Solve finds a path from start to goal, both included. It returns
+	// ErrNoPath when there's none and ctx.Err() when ctx is done first.
+	Solve(ctx context.Context, g Graph, start, goal Node) (Result, error)
+}
+
+// SolverFunc lets a function be used as a Solver
+type SolverFunc func(ctx context.Context, g Graph, start, goal Node) (Result, error)
+
+func (f SolverFunc) Solve(ctx context.Context, g Graph, start, goal Node) (Result, error) {
+	return f(ctx, g, start, goal)
+}
+
+// Guarantee is what the paths a solver finds are guaranteed to be
+type Guarantee int
+
+const (
+	// AnyPath solvers find a path whenever there's one, not always the shortest
+	AnyPath Guarantee = iota
+	// FewestEdges solvers find the paths with the fewest edges, which are the
+	// shortest ones when every edge costs the same, like on pixel grids
+	FewestEdges
+	// Cheapest solvers find the paths with the lowest cost, as long as the
+	// estimates they're given follow the rules of Estimator
+	Cheapest
+)
+
+// Guaranteed is implemented by solvers that report what their paths are guaranteed to be
+type Guaranteed interface {
+	Guarantee() Guarantee
+}
+
+// GuaranteeOf is what s guarantees, AnyPath for solvers that don't say
+func GuaranteeOf(s Solver) Guarantee {
+	if g, ok := s.(Guaranteed); ok {
+		return g.Guarantee()
+	}
+	return AnyPath
+}
+
+// guaranteed is a SolverFunc with what it guarantees
+type guaranteed struct {
+	SolverFunc
+	guarantee Guarantee
+}
+
+func (s guaranteed) Guarantee() Guarantee {
+	return s.guarantee
+}
+
+type Result struct {
+	Path []Node
+	// Cost is the sum of the costs of the steps along Path
+	Cost float64
+	// Expanded is the number of nodes whose neighbours were looked at
+	Expanded int
+	Elapsed  time.Duration
+}
+
+// how many nodes are expanded between checks of the context
+const ctxCheckInterval = 1024
+
+var solvers = make(map[string]Solver)
+
+// Register makes a solver available by name, it panics if the name is taken
+func Register(name string, s Solver) {
+	if _, ok := solvers[name]; ok {
+		panic(fmt.Sprintf("path: solver %q registered twice", name))
+	}
+	solvers[name] = s
+}
+
+func Lookup(name string) (Solver, bool) {
+	s, ok := solvers[name]
+	return s, ok
+}
+
+// Names are the names of every registered solver, sorted
+func Names() []string {
+	names := make([]string, 0, len(solvers))
+	for name := range solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,64 @@
+package path
+
+import "context"
+
+// Trace has hooks solvers call as they search, to watch them work. It's added
+// to the context given to Solve with WithTrace. Hooks are called on the
+// goroutine running the solver, which waits for them to return.
+type Trace struct {
+	// Expanded is called every time a solver expands a node, after it pushed
+	// the neighbours it's going to look at later
+	Expanded func(Step)
+}
+
+// Step is a node expanded by a solver
+type Step struct {
+	Node Node
+	// Pushed are the nodes added to the frontier from Node, or whose distance
+	// went down. It's only valid until Expanded returns.
+	Pushed []Node
+	// Frontier is the number of nodes waiting to be expanded after pushing them
+	Frontier int
+}
+
+type traceKey struct{}
+
+// WithTrace returns a copy of ctx solvers given it call the hooks of trace with
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// ContextTrace is the trace added to ctx, nil if there's none
+func ContextTrace(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// tracer gathers a Step for the Trace of a context. Solvers call it whether
+// there's a trace or not, a nil tracer does nothing.
+type tracer struct {
+	expanded func(Step)
+	pushed   []Node
+}
+
+func newTracer(ctx context.Context) *tracer {
+	trace := ContextTrace(ctx)
+	if trace == nil || trace.Expanded == nil {
+		return nil
+	}
+	return &tracer{expanded: trace.Expanded}
+}
+
+func (t *tracer) push(n Node) {
+	if t != nil {
+		t.pushed = append(t.pushed, n)
+	}
+}
+
+// expand reports n with the nodes pushed since the last node expanded
+func (t *tracer) expand(n Node, frontier int) {
+	if t != nil {
+		t.expanded(Step{Node: n, Pushed: t.pushed, Frontier: frontier})
+		t.pushed = t.pushed[:0]
+	}
+}
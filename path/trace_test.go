@@ -0,0 +1,59 @@
+package path
+
+import (
+	"context"
+	"image"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	g := NewGridGraph(readMaze(t, "../mazediag201x201.png"))
+	start, end := g.Node(image.Pt(0, 1)), g.Node(image.Pt(g.Grid.Width-1, g.Grid.Height-2))
+	for _, name := range Names() {
+		solver, _ := Lookup(name)
+		steps := 0
+		ctx := WithTrace(context.Background(), &Trace{Expanded: func(step Step) {
+			steps++
+			for _, n := range append([]Node{step.Node}, step.Pushed...) {
+				if n < 0 || int(n) >= g.Len() || !g.Open(n) {
+					t.Fatalf("%s: expected open nodes, but got: %d", name, n)
+				}
+			}
+			if step.Frontier < 0 {
+				t.Fatalf("%s: expected a frontier of at least 0 nodes, but got: %d", name, step.Frontier)
+			}
+		}})
+		res, err := solver.Solve(ctx, g, start, end)
+		if err != nil {
+			t.Fatalf("%s: expected no error, but got: %v", name, err)
+		}
+		if steps == 0 || steps != res.Expanded {
+			t.Fatalf("%s: expected a step for each of the %d nodes expanded, but got: %d", name, res.Expanded, steps)
+		}
+	}
+}
+
+func TestTraceFrontier(t *testing.T) {
+	g := openGrid(30, 20)
+	seen := map[Node]bool{g.Node(image.Pt(0, 0)): true}
+	expanded := 0
+	ctx := WithTrace(context.Background(), &Trace{Expanded: func(step Step) {
+		expanded++
+		for _, n := range step.Pushed {
+			if seen[n] {
+				t.Fatalf("Expected %v to be pushed once", g.Point(n))
+			}
+			seen[n] = true
+		}
+		// bfs pushes every node once, the ones not expanded yet are left
+		if step.Frontier != len(seen)-expanded {
+			t.Fatalf("Expected a frontier of %d nodes, but got: %d", len(seen)-expanded, step.Frontier)
+		}
+	}})
+	if _, err := BFS.Solve(ctx, g, g.Node(image.Pt(0, 0)), g.Node(image.Pt(29, 19))); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if ContextTrace(context.Background()) != nil {
+		t.Fatalf("Expected no trace without WithTrace")
+	}
+}
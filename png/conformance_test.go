@@ -0,0 +1,200 @@
+package png
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	stdpng "image/png"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// every valid combination of color type and bit depth, as in PngSuite's basic images
+var conformanceFormats = []struct {
+	colorType ColorType
+	bitDepths []uint8
+}{
+	{ColorTypeGrayscale, []uint8{1, 2, 4, 8, 16}},
+	{ColorTypeTruecolor, []uint8{8, 16}},
+	{ColorTypePalette, []uint8{1, 2, 4, 8}},
+	{ColorTypeGrayscaleAlpha, []uint8{8, 16}},
+	{ColorTypeTruecolorAlpha, []uint8{8, 16}},
+}
+
+var conformanceFilters = map[string][]FilterType{
+	"none":     {FilterTypeNone},
+	"sub":      {FilterTypeSub},
+	"up":       {FilterTypeUp},
+	"average":  {FilterTypeAverage},
+	"paeth":    {FilterTypePaeth},
+	"adaptive": {FilterTypeNone, FilterTypeSub, FilterTypeUp, FilterTypeAverage, FilterTypePaeth},
+}
+
+// sizes around the 8x8 Adam7 block, including images with empty passes
+var conformanceSizes = []image.Point{{1, 1}, {3, 2}, {8, 8}, {13, 11}, {33, 9}}
+
+func randomPng(rng *rand.Rand, width, height int, colorType ColorType, bitDepth uint8, interlace InterlaceMethod) *Png {
+	p := &Png{
+		Width:           width,
+		Height:          height,
+		ColorType:       colorType,
+		BitDepth:        bitDepth,
+		InterlaceMethod: interlace,
+		Pixels:          make([][]Pixel, height),
+	}
+	maxValue := uint(1)<<bitDepth - 1
+	// mostly smooth gradients so filters have something to work with, with some noise
+	sample := func(x, y, channel int) uint {
+		if rng.IntN(4) == 0 {
+			return rng.UintN(maxValue + 1)
+		}
+		return uint(x*(channel+1)+y*(3-channel)) % (maxValue + 1)
+	}
+	if colorType == ColorTypePalette {
+		p.PlteEntries = make([]TruecolorPixel, 1+rng.IntN(1<<bitDepth))
+		for i := range p.PlteEntries {
+			p.PlteEntries[i] = TruecolorPixel{Red: rng.UintN(256), Green: rng.UintN(256), Blue: rng.UintN(256), Alpha: 0xFF}
+			if rng.IntN(3) == 0 {
+				p.PlteEntries[i].Alpha = rng.UintN(256)
+			}
+		}
+	}
+	for y := range p.Pixels {
+		p.Pixels[y] = make([]Pixel, width)
+		for x := range p.Pixels[y] {
+			switch colorType {
+			case ColorTypeGrayscale:
+				p.Pixels[y][x] = &GreyscalePixel{Value: sample(x, y, 0)}
+			case ColorTypeGrayscaleAlpha:
+				p.Pixels[y][x] = &GreyscaleAlphaPixel{Value: sample(x, y, 0), Alpha: sample(x, y, 1)}
+			case ColorTypePalette:
+				p.Pixels[y][x] = &PalettePixel{Index: uint(rng.IntN(len(p.PlteEntries)))}
+			case ColorTypeTruecolor:
+				p.Pixels[y][x] = &TruecolorPixel{Red: sample(x, y, 0), Green: sample(x, y, 1), Blue: sample(x, y, 2), Alpha: maxValue}
+			case ColorTypeTruecolorAlpha:
+				p.Pixels[y][x] = &TruecolorPixel{Red: sample(x, y, 0), Green: sample(x, y, 1), Blue: sample(x, y, 2), Alpha: sample(x, y, 3)}
+			}
+		}
+	}
+	// sometimes key out the color of the first pixel
+	if (colorType == ColorTypeGrayscale || colorType == ColorTypeTruecolor) && rng.IntN(2) == 0 {
+		switch pixel := p.Pixels[0][0].(type) {
+		case *GreyscalePixel:
+			p.Transparent = &TruecolorPixel{Red: pixel.Value, Green: pixel.Value, Blue: pixel.Value}
+		case *TruecolorPixel:
+			p.Transparent = &TruecolorPixel{Red: pixel.Red, Green: pixel.Green, Blue: pixel.Blue}
+		}
+	}
+	return p
+}
+
+// nrgba64At is the exact non premultiplied color at x, y, converting translucent
+// colors through color.NRGBA64Model would lose precision to premultiplication
+func nrgba64At(img image.Image, x, y int) color.NRGBA64 {
+	switch c := img.At(x, y).(type) {
+	case color.NRGBA:
+		return color.NRGBA64{R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101, B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101}
+	case color.NRGBA64:
+		return c
+	default:
+		return color.NRGBA64Model.Convert(c).(color.NRGBA64)
+	}
+}
+
+// compareWithImagePng decodes data with both DecodePng and image/png and fails on the first differing pixel
+func compareWithImagePng(t *testing.T, data []byte) *Png {
+	t.Helper()
+	res, err := DecodePng(data)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	got, err := res.ToNRGBA64()
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	expected, err := stdpng.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("image/png couldn't decode the image: %v", err)
+	}
+	if got.Rect != expected.Bounds() {
+		t.Fatalf("Expected bounds %v, but got: %v", expected.Bounds(), got.Rect)
+	}
+	for y := 0; y < res.Height; y++ {
+		for x := 0; x < res.Width; x++ {
+			e := nrgba64At(expected, x, y)
+			if g := got.At(x, y); g != e {
+				t.Fatalf("pixel (%d, %d) expected %v, but got: %v", x, y, e, g)
+			}
+		}
+	}
+	return res
+}
+
+func TestConformanceAgainstImagePng(t *testing.T) {
+	rng := rand.New(rand.NewPCG(31, 2001))
+	for _, format := range conformanceFormats {
+		for _, bitDepth := range format.bitDepths {
+			for _, interlace := range []InterlaceMethod{InterlaceMethodNone, InterlaceMethodAdam7} {
+				for filterName, filters := range conformanceFilters {
+					for _, size := range conformanceSizes {
+						name := fmt.Sprintf("type%d/%dbit/interlace%d/%s/%dx%d", format.colorType, bitDepth, interlace, filterName, size.X, size.Y)
+						p := randomPng(rng, size.X, size.Y, format.colorType, bitDepth, interlace)
+						t.Run(name, func(t *testing.T) {
+							var buf bytes.Buffer
+							if err := Encode(&buf, p, EncodeOptions{Filters: filters}); err != nil {
+								t.Fatalf("Expected no error, but got: %v", err)
+							}
+							res := compareWithImagePng(t, buf.Bytes())
+							if !reflect.DeepEqual(res.Pixels, p.Pixels) {
+								t.Fatal("decoded pixels differ from the encoded ones")
+							}
+
+							err := DecodeRows(bytes.NewReader(buf.Bytes()), func(y int, row RowView) error {
+								for x := 0; x < row.Width(); x++ {
+									if !reflect.DeepEqual(row.Pixel(x), res.Pixels[y][x]) {
+										return fmt.Errorf("pixel (%d, %d) expected %v, but got: %v", x, y, res.Pixels[y][x], row.Pixel(x))
+									}
+								}
+								return nil
+							})
+							if err != nil {
+								t.Fatalf("DecodeRows: %v", err)
+							}
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestConformanceWithFixtures decodes the sample images checked in at the root of the repo
+func TestConformanceWithFixtures(t *testing.T) {
+	files, err := filepath.Glob("../*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := stdpng.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if config.Width*config.Height > 1000*1000 && testing.Short() {
+			continue
+		}
+		if config.Width*config.Height > 2001*2001 {
+			continue
+		}
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			compareWithImagePng(t, data)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package png
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// ScaleSample converts a sample between bit depths rounding to the nearest
+// value, so 16 -> 8 maps 0xFF7F to 0xFF instead of truncating it to 0xFE.
+// Going to a higher bit depth replicates the bits, 8 -> 16 maps 0xAB to 0xABAB.
+func ScaleSample(v uint, from, to uint8) uint {
+	if from == to {
+		return v
+	}
+	fromMax := uint(1)<<from - 1
+	toMax := uint(1)<<to - 1
+	return (v*toMax + fromMax/2) / fromMax
+}
+
+// truecolorBitDepth is the bit depth of pixels expanded by ToTruecolor, palette
+// entries are always 8 bits and grayscale below 8 bits is scaled up to 8
+func truecolorBitDepth(bitDepth uint8) uint8 {
+	if bitDepth == 16 {
+		return 16
+	}
+	return 8
+}
+
+// ToTruecolor expands palette and greyscale pixels into truecolor ones. The
+// result is 16 bits for 16 bit images and 8 bits for everything else.
+func ToTruecolor(pixel Pixel, bitDepth uint8, palette []TruecolorPixel) (TruecolorPixel, error) {
+	depth := truecolorBitDepth(bitDepth)
+	opaque := uint(1)<<depth - 1
+
+	switch p := pixel.(type) {
+	case *TruecolorPixel:
+		return *p, nil
+	case *GreyscalePixel:
+		v := ScaleSample(p.Value, bitDepth, depth)
+		return TruecolorPixel{Red: v, Green: v, Blue: v, Alpha: opaque}, nil
+	case *GreyscaleAlphaPixel:
+		return TruecolorPixel{Red: p.Value, Green: p.Value, Blue: p.Value, Alpha: p.Alpha}, nil
+	case *PalettePixel:
+		if p.Index >= uint(len(palette)) {
+			return TruecolorPixel{}, fmt.Errorf("palette index %d out of range, palette has %d entries", p.Index, len(palette))
+		}
+		return palette[p.Index], nil
+	}
+	return TruecolorPixel{}, fmt.Errorf("unknown pixel type %T", pixel)
+}
+
+// To8Bit converts a 16 bit truecolor pixel to 8 bits, rounding every channel
+func To8Bit(p TruecolorPixel) TruecolorPixel {
+	return TruecolorPixel{
+		Red:   ScaleSample(p.Red, 16, 8),
+		Green: ScaleSample(p.Green, 16, 8),
+		Blue:  ScaleSample(p.Blue, 16, 8),
+		Alpha: ScaleSample(p.Alpha, 16, 8),
+	}
+}
+
+// To16Bit converts an 8 bit truecolor pixel to 16 bits, replicating every channel
+func To16Bit(p TruecolorPixel) TruecolorPixel {
+	return TruecolorPixel{
+		Red:   ScaleSample(p.Red, 8, 16),
+		Green: ScaleSample(p.Green, 8, 16),
+		Blue:  ScaleSample(p.Blue, 8, 16),
+		Alpha: ScaleSample(p.Alpha, 8, 16),
+	}
+}
+
+// Premultiply scales the color channels by alpha, as image.RGBA expects them
+func Premultiply(p TruecolorPixel, bitDepth uint8) TruecolorPixel {
+	maxValue := uint(1)<<bitDepth - 1
+	premultiply := func(v uint) uint {
+		return (v*p.Alpha + maxValue/2) / maxValue
+	}
+	return TruecolorPixel{
+		Red:   premultiply(p.Red),
+		Green: premultiply(p.Green),
+		Blue:  premultiply(p.Blue),
+		Alpha: p.Alpha,
+	}
+}
+
+// Luminance is the ITU-R BT.601 luma of the pixel at its own bit depth, with
+// the same weights image/color uses for its gray models
+func Luminance(p TruecolorPixel) uint {
+	return (19595*p.Red + 38470*p.Green + 7471*p.Blue + 1<<15) >> 16
+}
+
+// ToNRGBA64 expands the whole image to 16 bit non premultiplied RGBA, pixels
+// of the Transparent color get an alpha of 0
+func (p *Png) ToNRGBA64() (*image.NRGBA64, error) {
+	img := image.NewNRGBA64(image.Rect(0, 0, p.Width, p.Height))
+	depth := truecolorBitDepth(p.BitDepth)
+	for y, row := range p.Pixels {
+		for x, pixel := range row {
+			c, err := ToTruecolor(pixel, p.BitDepth, p.PlteEntries)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't convert pixel (%d, %d): %w", x, y, err)
+			}
+			if p.IsTransparent(pixel) {
+				c.Alpha = 0
+			}
+			if depth == 8 {
+				c = To16Bit(c)
+			}
+			img.SetNRGBA64(x, y, color.NRGBA64{R: uint16(c.Red), G: uint16(c.Green), B: uint16(c.Blue), A: uint16(c.Alpha)})
+		}
+	}
+	return img, nil
+}
+
+// IsTransparent reports whether pixel has the Transparent color, which makes
+// it fully transparent
+func (p *Png) IsTransparent(pixel Pixel) bool {
+	if p.Transparent == nil {
+		return false
+	}
+	key := p.Transparent
+	switch px := pixel.(type) {
+	case *GreyscalePixel:
+		return px.Value == key.Red
+	case *TruecolorPixel:
+		return p.ColorType == ColorTypeTruecolor && px.Red == key.Red && px.Green == key.Green && px.Blue == key.Blue
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package png
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestScaleSample16To8Rounds(t *testing.T) {
+	for v := uint(0); v <= 0xFFFF; v++ {
+		expected := uint(math.Round(float64(v) * 255 / 65535))
+		if got := ScaleSample(v, 16, 8); got != expected {
+			t.Fatalf("%#04x expected %#02x, but got: %#02x", v, expected, got)
+		}
+	}
+	if got := To8Bit(TruecolorPixel{Red: 0xFF7F}).Red; got != 0xFF {
+		t.Fatalf("Expected 0xFF7F to round up to 0xFF, but got: %#02x", got)
+	}
+}
+
+func TestScaleSampleUpReplicatesBits(t *testing.T) {
+	for v := uint(0); v <= 0xFF; v++ {
+		if got := ScaleSample(v, 8, 16); got != v<<8|v {
+			t.Fatalf("%#02x expected %#04x, but got: %#04x", v, v<<8|v, got)
+		}
+	}
+	tests := []struct {
+		value    uint
+		bitDepth uint8
+		expected uint
+	}{
+		{0b1, 1, 0xFF},
+		{0b10, 2, 0b10101010},
+		{0b01, 2, 0b01010101},
+		{0b1001, 4, 0b10011001},
+		{0, 4, 0},
+	}
+	for _, test := range tests {
+		if got := ScaleSample(test.value, test.bitDepth, 8); got != test.expected {
+			t.Fatalf("%b at %d bits expected %#08b, but got: %#08b", test.value, test.bitDepth, test.expected, got)
+		}
+	}
+}
+
+func TestToTruecolor(t *testing.T) {
+	palette := []TruecolorPixel{
+		{Red: 10, Green: 20, Blue: 30, Alpha: 0xFF},
+		{Red: 40, Green: 50, Blue: 60, Alpha: 0x80},
+	}
+	tests := []struct {
+		pixel    Pixel
+		bitDepth uint8
+		expected TruecolorPixel
+	}{
+		{&PalettePixel{Index: 1}, 2, palette[1]},
+		{&GreyscalePixel{Value: 0b10}, 2, TruecolorPixel{Red: 0xAA, Green: 0xAA, Blue: 0xAA, Alpha: 0xFF}},
+		{&GreyscalePixel{Value: 0x1234}, 16, TruecolorPixel{Red: 0x1234, Green: 0x1234, Blue: 0x1234, Alpha: 0xFFFF}},
+		{&GreyscaleAlphaPixel{Value: 0x12, Alpha: 0x34}, 8, TruecolorPixel{Red: 0x12, Green: 0x12, Blue: 0x12, Alpha: 0x34}},
+		{&TruecolorPixel{Red: 1, Green: 2, Blue: 3, Alpha: 4}, 8, TruecolorPixel{Red: 1, Green: 2, Blue: 3, Alpha: 4}},
+	}
+	for _, test := range tests {
+		got, err := ToTruecolor(test.pixel, test.bitDepth, palette)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		if got != test.expected {
+			t.Fatalf("%#v expected %v, but got: %v", test.pixel, test.expected, got)
+		}
+	}
+
+	_, err := ToTruecolor(&PalettePixel{Index: 2}, 2, palette)
+	if err == nil {
+		t.Fatal("Expected error due to palette index out of range, but got no error")
+	}
+}
+
+func TestPremultiply(t *testing.T) {
+	tests := []struct {
+		pixel    TruecolorPixel
+		bitDepth uint8
+		expected TruecolorPixel
+	}{
+		{TruecolorPixel{200, 100, 50, 128}, 8, TruecolorPixel{100, 50, 25, 128}},
+		{TruecolorPixel{255, 255, 255, 0}, 8, TruecolorPixel{0, 0, 0, 0}},
+		{TruecolorPixel{1, 2, 3, 255}, 8, TruecolorPixel{1, 2, 3, 255}},
+		{TruecolorPixel{0xFFFF, 0x8000, 0x0001, 0x8000}, 16, TruecolorPixel{0x8000, 0x4000, 0x0001, 0x8000}},
+	}
+	for _, test := range tests {
+		if got := Premultiply(test.pixel, test.bitDepth); got != test.expected {
+			t.Fatalf("%v expected %v, but got: %v", test.pixel, test.expected, got)
+		}
+	}
+}
+
+func TestLuminanceMatchesImageColor(t *testing.T) {
+	for _, c := range []color.RGBA64{
+		{0, 0, 0, 0xFFFF},
+		{0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF},
+		{0xFFFF, 0, 0, 0xFFFF},
+		{0, 0xFFFF, 0, 0xFFFF},
+		{0, 0, 0xFFFF, 0xFFFF},
+		{0x1234, 0x5678, 0x9ABC, 0xFFFF},
+		{0x8000, 0x7FFF, 0x0101, 0xFFFF},
+	} {
+		expected := uint(color.Gray16Model.Convert(c).(color.Gray16).Y)
+		got := Luminance(TruecolorPixel{Red: uint(c.R), Green: uint(c.G), Blue: uint(c.B), Alpha: uint(c.A)})
+		if got != expected {
+			t.Fatalf("%v expected %#04x, but got: %#04x", c, expected, got)
+		}
+	}
+	if got := Luminance(TruecolorPixel{Red: 255, Green: 255, Blue: 255}); got != 255 {
+		t.Fatalf("Expected white to be 255 at 8 bits, but got: %d", got)
+	}
+}
+
+func TestToNRGBA64(t *testing.T) {
+	palette := color.Palette{
+		color.NRGBA{R: 0xFF, A: 0xFF},
+		color.NRGBA{G: 0xFF, A: 0xFF},
+		color.NRGBA{B: 0xFF, A: 0xFF},
+	}
+	src := image.NewPaletted(image.Rect(0, 0, 7, 3), palette)
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 7; x++ {
+			src.SetColorIndex(x, y, uint8((x+y)%3))
+		}
+	}
+
+	res, err := DecodePng(encodeTestImage(t, src))
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	img, err := res.ToNRGBA64()
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 7; x++ {
+			expected := color.NRGBA64Model.Convert(src.At(x, y))
+			if got := img.At(x, y); got != expected {
+				t.Fatalf("pixel (%d, %d) expected %v, but got: %v", x, y, expected, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package png
+
+import (
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+type CompressionLevel int
+
+const (
+	DefaultCompression CompressionLevel = 0
+	NoCompression      CompressionLevel = -1
+	BestSpeed          CompressionLevel = -2
+	BestCompression    CompressionLevel = -3
+	// levels 1 to 9 are passed to zlib as they are
+)
+
+func (l CompressionLevel) String() string {
+	switch l {
+	case DefaultCompression:
+		return "default"
+	case NoCompression:
+		return "none"
+	case BestSpeed:
+		return "best speed"
+	case BestCompression:
+		return "best"
+	}
+	return strconv.Itoa(int(l))
+}
+
+func (l CompressionLevel) zlibLevel() (int, error) {
+	switch l {
+	case DefaultCompression:
+		return zlib.DefaultCompression, nil
+	case NoCompression:
+		return zlib.NoCompression, nil
+	case BestSpeed:
+		return zlib.BestSpeed, nil
+	case BestCompression:
+		return zlib.BestCompression, nil
+	}
+	if l < 1 || l > 9 {
+		return 0, fmt.Errorf("invalid compression level: %d", l)
+	}
+	return int(l), nil
+}
+
+type EncodeOptions struct {
+	// Filters are tried on every scanline, keeping the one whose filtered bytes
+	// have the smallest sum of absolute values. nil uses None for palette and
+	// sub byte images and tries all of them otherwise, as the spec recommends.
+	Filters          []FilterType
+	CompressionLevel CompressionLevel
+	// ChunkSize is the size of the IDAT chunks the compressed data is split in, 0 uses 64KiB
+	ChunkSize int
+}
+
+const defaultChunkSize = 64 * 1024
+
+// Encode writes p as a png, using the color type, bit depth and interlace
+// method it has. Pixels must be of the type matching the color type. Palette
+// entries with an alpha other than 0xFF and the Transparent color of grayscale
+// and truecolor images are written to a tRNS chunk.
+func Encode(w io.Writer, p *Png, opts EncodeOptions) error {
+	return encode(w, p, opts, nil, nil)
+}
+
+// encode also writes ancillary chunks, the ones in beforePLTE are written
+// right after IHDR and the ones in afterPLTE right before IDAT
+func encode(w io.Writer, p *Png, opts EncodeOptions, beforePLTE, afterPLTE []*Chunk) error {
+	header := IHDRData{
+		Width:           p.Width,
+		Height:          p.Height,
+		BitDepth:        p.BitDepth,
+		ColorType:       p.ColorType,
+		InterlaceMethod: p.InterlaceMethod,
+	}
+	if len(p.Pixels) != p.Height {
+		return fmt.Errorf("expected %d rows of pixels, got: %d", p.Height, len(p.Pixels))
+	}
+	for y, pixels := range p.Pixels {
+		if len(pixels) != p.Width {
+			return fmt.Errorf("expected %d pixels in row %d, got: %d", p.Width, y, len(pixels))
+		}
+	}
+	if p.Transparent != nil {
+		trns, err := encodeTransparentColor(header, *p.Transparent)
+		if err != nil {
+			return err
+		}
+		// tRNS goes after PLTE, which these color types don't have
+		afterPLTE = append([]*Chunk{{chunkType: TRNS, data: trns}}, afterPLTE...)
+	}
+
+	e, err := newRowEncoder(w, header, p.PlteEntries, opts, beforePLTE, afterPLTE)
+	if err != nil {
+		return err
+	}
+	passes := []adam7Pass{{0, 0, 1, 1}}
+	if header.InterlaceMethod == InterlaceMethodAdam7 {
+		passes = adam7Passes
+	}
+	for _, pass := range passes {
+		passHeader := pass.header(header)
+		if passHeader.Width == 0 || passHeader.Height == 0 {
+			continue
+		}
+		e.filter = newScanlineFilter(passHeader, e.filter.filters)
+		row := make([]Pixel, passHeader.Width)
+		for py := 0; py < passHeader.Height; py++ {
+			pixels := p.Pixels[pass.yStart+py*pass.yStep]
+			for px := range row {
+				row[px] = pixels[pass.xStart+px*pass.xStep]
+			}
+			raw, err := packScanline(passHeader, row, e.filter.raw)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", pass.yStart+py*pass.yStep, err)
+			}
+			if err := e.writeScanline(raw); err != nil {
+				return err
+			}
+		}
+	}
+	// passes don't add up to Height scanlines, but all of them were written
+	e.rows = header.Height
+	return e.Close()
+}
+
+func defaultFilters(header IHDRData) []FilterType {
+	if header.ColorType == ColorTypePalette || header.BitDepth < 8 {
+		return []FilterType{FilterTypeNone}
+	}
+	return []FilterType{FilterTypeNone, FilterTypeSub, FilterTypeUp, FilterTypeAverage, FilterTypePaeth}
+}
+
+func encodeIHDRChunk(header IHDRData) []byte {
+	data := make([]byte, 13)
+	binary.BigEndian.PutUint32(data[:4], uint32(header.Width))
+	binary.BigEndian.PutUint32(data[4:8], uint32(header.Height))
+	data[8] = header.BitDepth
+	switch header.ColorType {
+	case ColorTypeGrayscale:
+		data[9] = 0
+	case ColorTypeTruecolor:
+		data[9] = 2
+	case ColorTypePalette:
+		data[9] = 3
+	case ColorTypeGrayscaleAlpha:
+		data[9] = 4
+	case ColorTypeTruecolorAlpha:
+		data[9] = 6
+	default:
+		data[9] = 0xFF
+	}
+	data[12] = byte(header.InterlaceMethod)
+	return data
+}
+
+// encodePLTEChunk also returns the tRNS chunk data, trailing opaque entries
+// are left out of it and it's empty when the whole palette is opaque
+func encodePLTEChunk(entries []TruecolorPixel) ([]byte, []byte) {
+	plte := make([]byte, 0, len(entries)*3)
+	trns := make([]byte, 0, len(entries))
+	lastTranslucent := -1
+	for i, entry := range entries {
+		plte = append(plte, byte(entry.Red), byte(entry.Green), byte(entry.Blue))
+		trns = append(trns, byte(entry.Alpha))
+		if entry.Alpha != 0xFF {
+			lastTranslucent = i
+		}
+	}
+	return plte, trns[:lastTranslucent+1]
+}
+
+// encodeTransparentColor is the tRNS chunk data of grayscale and truecolor images
+func encodeTransparentColor(header IHDRData, c TruecolorPixel) ([]byte, error) {
+	samples := []uint{c.Red, c.Green, c.Blue}
+	switch header.ColorType {
+	case ColorTypeGrayscale:
+		samples = samples[:1]
+	case ColorTypeTruecolor:
+	default:
+		return nil, fmt.Errorf("a transparent color can't be written for color type %s", header.ColorType)
+	}
+	data := make([]byte, 0, 2*len(samples))
+	for _, v := range samples {
+		if v >= 1<<header.BitDepth {
+			return nil, fmt.Errorf("transparent color sample %d doesn't fit in %d bits", v, header.BitDepth)
+		}
+		data = binary.BigEndian.AppendUint16(data, uint16(v))
+	}
+	return data, nil
+}
+
+func writeChunk(w io.Writer, chunkType ChunkType, data []byte) error {
+	buf := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(data)))
+	copy(buf[4:8], chunkType)
+	buf = append(buf, data...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+	_, err := w.Write(buf)
+	return err
+}
+
+// packScanline writes the samples of pixels into raw, the scanline without the filter type byte
+func packScanline(header IHDRData, pixels []Pixel, raw []byte) ([]byte, error) {
+	clear(raw)
+	bitDepth := int(header.BitDepth)
+	channels := header.pixelNumChannels()
+	var samples [4]uint
+	for x, pixel := range pixels {
+		ok := false
+		switch p := pixel.(type) {
+		case *GreyscalePixel:
+			ok = header.ColorType == ColorTypeGrayscale
+			samples[0] = p.Value
+		case *GreyscaleAlphaPixel:
+			ok = header.ColorType == ColorTypeGrayscaleAlpha
+			samples[0], samples[1] = p.Value, p.Alpha
+		case *PalettePixel:
+			ok = header.ColorType == ColorTypePalette
+			samples[0] = p.Index
+		case *TruecolorPixel:
+			ok = header.ColorType == ColorTypeTruecolor || header.ColorType == ColorTypeTruecolorAlpha
+			samples = [4]uint{p.Red, p.Green, p.Blue, p.Alpha}
+		}
+		if !ok {
+			return nil, fmt.Errorf("pixel %d is a %T, which doesn't match color type %v", x, pixel, header.ColorType)
+		}
+		for c, v := range samples[:channels] {
+			if v >= 1<<bitDepth {
+				return nil, fmt.Errorf("pixel %d sample %d doesn't fit in %d bits", x, v, bitDepth)
+			}
+			setSample(raw, bitDepth, x*channels+c, v)
+		}
+	}
+	return raw, nil
+}
+
+// scanlineFilter filters consecutive scanlines of an image, remembering the previous one
+type scanlineFilter struct {
+	filters []FilterType
+	bpp     int
+	raw     []byte
+	prior   []byte
+	// filter type byte followed by the filtered scanline, for the filter being tried and the best one so far
+	candidate []byte
+	best      []byte
+}
+
+func newScanlineFilter(header IHDRData, filters []FilterType) *scanlineFilter {
+	size := header.scanlineByteSize()
+	return &scanlineFilter{
+		filters:   filters,
+		bpp:       maxInt(header.pixelByteSize(), 1),
+		raw:       make([]byte, size),
+		prior:     make([]byte, size),
+		candidate: make([]byte, size+1),
+		best:      make([]byte, size+1),
+	}
+}
+
+// filter returns raw filtered with the best of the filters, prefixed with the
+// filter type byte. The result is only valid until the next call.
+func (f *scanlineFilter) filter(raw []byte) []byte {
+	bestSum := -1
+	for _, filterType := range f.filters {
+		f.candidate[0] = byte(filterType)
+		filterScanline(filterType, f.candidate[1:], raw, f.prior, f.bpp)
+		sum := 0
+		if len(f.filters) > 1 {
+			for _, b := range f.candidate[1:] {
+				sum += absInt(int(int8(b)))
+			}
+		}
+		if bestSum == -1 || sum < bestSum {
+			bestSum = sum
+			f.best, f.candidate = f.candidate, f.best
+		}
+	}
+	copy(f.prior, raw)
+	return f.best
+}
+
+// filterScanline is the inverse of unfilterScanline, prior is all zeroes for the first scanline
+func filterScanline(filterType FilterType, dst, raw, prior []byte, bpp int) {
+	for i := range raw {
+		var a, c int
+		if i >= bpp {
+			a = int(raw[i-bpp])
+			c = int(prior[i-bpp])
+		}
+		b := int(prior[i])
+		x := int(raw[i])
+		switch filterType {
+		case FilterTypeNone:
+			dst[i] = byte(x)
+		case FilterTypeSub:
+			dst[i] = byte(x - a)
+		case FilterTypeUp:
+			dst[i] = byte(x - b)
+		case FilterTypeAverage:
+			dst[i] = byte(x - (a+b)/2)
+		case FilterTypePaeth:
+			dst[i] = byte(x - paethPredictor(a, b, c))
+		}
+	}
+}
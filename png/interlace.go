@@ -0,0 +1,64 @@
+package png
+
+import "context"
+
+type adam7Pass struct {
+	xStart, yStart int
+	xStep, yStep   int
+}
+
+var adam7Passes = []adam7Pass{
+	{0, 0, 8, 8},
+	{4, 0, 8, 8},
+	{0, 4, 4, 8},
+	{2, 0, 4, 4},
+	{0, 2, 2, 4},
+	{1, 0, 2, 2},
+	{0, 1, 1, 2},
+}
+
+// header describes the reduced image of the pass, which can have no pixels at all
+func (p adam7Pass) header(header IHDRData) IHDRData {
+	header.Width = ceilDiv(maxInt(header.Width-p.xStart, 0), p.xStep)
+	header.Height = ceilDiv(maxInt(header.Height-p.yStart, 0), p.yStep)
+	header.InterlaceMethod = InterlaceMethodNone
+	return header
+}
+
+// deinterlaceAdam7 unfilters the reduced image of every pass and puts its pixels
+// in their place in full size scanlines, returning how many bytes the passes took
+func deinterlaceAdam7(ctx context.Context, idatData []byte, header IHDRData) ([]*Scanline, int, error) {
+	scanlineByteSize := header.scanlineByteSize()
+	scanlines := make([]*Scanline, header.Height)
+	for y := range scanlines {
+		scanlines[y] = &Scanline{index: y, data: make([]byte, scanlineByteSize)}
+	}
+
+	channels := header.pixelNumChannels()
+	bitDepth := int(header.BitDepth)
+	read := 0
+	for _, pass := range adam7Passes {
+		passHeader := pass.header(header)
+		// empty passes don't have any scanlines, not even the filter type byte
+		if passHeader.Width == 0 || passHeader.Height == 0 {
+			continue
+		}
+		passScanlines, n, err := unfilterScanlines(ctx, idatData[read:], passHeader)
+		if err != nil {
+			return nil, 0, err
+		}
+		read += n
+
+		for py, sl := range passScanlines {
+			data := scanlines[pass.yStart+py*pass.yStep].data
+			for px := 0; px < passHeader.Width; px++ {
+				x := pass.xStart + px*pass.xStep
+				for c := 0; c < channels; c++ {
+					setSample(data, bitDepth, x*channels+c, sample(sl.data, bitDepth, px*channels+c))
+				}
+			}
+		}
+	}
+
+	return scanlines, read, nil
+}
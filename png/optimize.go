@@ -0,0 +1,384 @@
+package png
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+type OptimizeOptions struct {
+	// KeepChunks lists the ancillary chunks copied to the optimized image, every
+	// other one is stripped. Chunks describing colors of the original color type
+	// (bKGD, sBIT, hIST) are dropped anyway if the color type or bit depth change.
+	KeepChunks []ChunkType
+	// Filters are the filter strategies tried, nil tries each filter on its own
+	// and the adaptive choice between all of them
+	Filters [][]FilterType
+	// CompressionLevels are the levels tried, nil only tries BestCompression
+	CompressionLevels []CompressionLevel
+}
+
+type OptimizeResult struct {
+	Data             []byte
+	ColorType        ColorType
+	BitDepth         uint8
+	Filters          []FilterType
+	CompressionLevel CompressionLevel
+	// DroppedChunks were asked to be kept but don't apply to the new color type
+	DroppedChunks []ChunkType
+}
+
+var allFilters = []FilterType{FilterTypeNone, FilterTypeSub, FilterTypeUp, FilterTypeAverage, FilterTypePaeth}
+
+// chunks that have to come before PLTE, all other ancillary chunks are written after it
+var beforePLTEChunks = []ChunkType{"cHRM", "gAMA", "iCCP", "sBIT", "sRGB"}
+
+// chunks whose data depends on the color type and bit depth
+var colorChunks = []ChunkType{"bKGD", "sBIT", "hIST"}
+
+// Optimize losslessly recompresses a png. It reduces the color type and bit
+// depth to the smallest that can hold every pixel, tries every filter strategy
+// and compression level, and checks the smallest result decodes to the same
+// pixels as data.
+func Optimize(data []byte, opts OptimizeOptions) (*OptimizeResult, error) {
+	original, err := DecodePng(data)
+	if err != nil {
+		return nil, err
+	}
+	img, err := original.ToNRGBA64()
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := readAncillaryChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := opts.Filters
+	if filters == nil {
+		for _, filter := range allFilters {
+			filters = append(filters, []FilterType{filter})
+		}
+		filters = append(filters, allFilters)
+	}
+	levels := opts.CompressionLevels
+	if levels == nil {
+		levels = []CompressionLevel{BestCompression}
+	}
+
+	// combinations are encoded concurrently, a processor each, the smallest wins
+	var (
+		mu       sync.Mutex
+		best     *OptimizeResult
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for _, candidate := range reducedPngs(img) {
+		before, after, dropped := keptChunks(chunks, opts.KeepChunks, candidate.header.ColorType != original.ColorType || candidate.header.BitDepth != original.BitDepth)
+		for _, filter := range filters {
+			for _, level := range levels {
+				sem <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-sem }()
+
+					var buf bytes.Buffer
+					err := candidate.encode(&buf, img, EncodeOptions{Filters: filter, CompressionLevel: level}, before, after)
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err != nil:
+						if firstErr == nil {
+							firstErr = err
+						}
+					case best == nil || buf.Len() < len(best.Data):
+						best = &OptimizeResult{
+							Data:             buf.Bytes(),
+							ColorType:        candidate.header.ColorType,
+							BitDepth:         candidate.header.BitDepth,
+							Filters:          filter,
+							CompressionLevel: level,
+							DroppedChunks:    dropped,
+						}
+					}
+				}()
+			}
+		}
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	optimized, err := DecodePng(best.Data)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode optimized image: %w", err)
+	}
+	optimizedImg, err := optimized.ToNRGBA64()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode optimized image: %w", err)
+	}
+	if !bytes.Equal(optimizedImg.Pix, img.Pix) {
+		return nil, errors.New("optimized image pixels differ from the original ones")
+	}
+
+	return best, nil
+}
+
+// reducedPngs are the smallest representations of img for each color type that can hold it
+func reducedPngs(img *image.NRGBA64) []*reducedPng {
+	opaque, gray, fits8Bit := true, true, true
+	colors := make(map[color.NRGBA64]int)
+	for i := 0; i < len(img.Pix); i += 8 {
+		c := nrgba64FromPix(img.Pix[i:])
+		opaque = opaque && c.A == 0xFFFF
+		gray = gray && c.R == c.G && c.G == c.B
+		fits8Bit = fits8Bit && c.R%0x101 == 0 && c.G%0x101 == 0 && c.B%0x101 == 0 && c.A%0x101 == 0
+		if len(colors) <= 256 {
+			colors[c]++
+		}
+	}
+	key := transparentColor(img)
+
+	bitDepth := uint8(16)
+	if fits8Bit {
+		bitDepth = 8
+	}
+
+	candidates := make([]*reducedPng, 0)
+	grayBitDepth := bitDepth
+	if fits8Bit {
+		grayBitDepth = minGrayBitDepth(colors)
+	}
+	if gray && opaque {
+		candidates = append(candidates, newReducedPng(img, ColorTypeGrayscale, grayBitDepth, nil, nil))
+	} else if gray {
+		candidates = append(candidates, newReducedPng(img, ColorTypeGrayscaleAlpha, bitDepth, nil, nil))
+		if key != nil {
+			candidates = append(candidates, newReducedPng(img, ColorTypeGrayscale, grayBitDepth, nil, key))
+		}
+	}
+	if fits8Bit && len(colors) <= 256 {
+		palette := make([]color.NRGBA64, 0, len(colors))
+		for c := range colors {
+			palette = append(palette, c)
+		}
+		// translucent entries first so the tRNS chunk is as short as possible, then the most used
+		slices.SortFunc(palette, func(a, b color.NRGBA64) int {
+			if (a.A == 0xFFFF) != (b.A == 0xFFFF) {
+				if a.A != 0xFFFF {
+					return -1
+				}
+				return 1
+			}
+			return cmp.Compare(colors[b], colors[a])
+		})
+		candidates = append(candidates, newReducedPng(img, ColorTypePalette, paletteBitDepth(len(palette)), palette, nil))
+	}
+	if opaque {
+		candidates = append(candidates, newReducedPng(img, ColorTypeTruecolor, bitDepth, nil, nil))
+	} else {
+		candidates = append(candidates, newReducedPng(img, ColorTypeTruecolorAlpha, bitDepth, nil, nil))
+		if key != nil {
+			candidates = append(candidates, newReducedPng(img, ColorTypeTruecolor, bitDepth, nil, key))
+		}
+	}
+
+	return candidates
+}
+
+// transparentColor is the color every translucent pixel of img has when they're
+// all fully transparent and no opaque pixel has it, so a tRNS chunk can stand
+// in for an alpha channel. It's nil otherwise.
+func transparentColor(img *image.NRGBA64) *color.NRGBA64 {
+	var key *color.NRGBA64
+	for i := 0; i < len(img.Pix); i += 8 {
+		c := nrgba64FromPix(img.Pix[i:])
+		switch {
+		case c.A == 0xFFFF:
+		case c.A != 0 || key != nil && *key != c:
+			return nil
+		case key == nil:
+			key = &c
+		}
+	}
+	if key == nil {
+		return nil
+	}
+	for i := 0; i < len(img.Pix); i += 8 {
+		if c := nrgba64FromPix(img.Pix[i:]); c.A == 0xFFFF && c.R == key.R && c.G == key.G && c.B == key.B {
+			return nil
+		}
+	}
+	return key
+}
+
+func nrgba64FromPix(pix []byte) color.NRGBA64 {
+	return color.NRGBA64{
+		R: uint16(pix[0])<<8 | uint16(pix[1]),
+		G: uint16(pix[2])<<8 | uint16(pix[3]),
+		B: uint16(pix[4])<<8 | uint16(pix[5]),
+		A: uint16(pix[6])<<8 | uint16(pix[7]),
+	}
+}
+
+// minGrayBitDepth is the smallest bit depth whose values, scaled up to 8 bits, are all of the gray levels
+func minGrayBitDepth(colors map[color.NRGBA64]int) uint8 {
+	if len(colors) > 256 {
+		return 8
+	}
+	for _, bitDepth := range []uint8{1, 2, 4} {
+		fits := true
+		for c := range colors {
+			v := uint(c.R >> 8)
+			fits = fits && ScaleSample(ScaleSample(v, 8, bitDepth), bitDepth, 8) == v
+		}
+		if fits {
+			return bitDepth
+		}
+	}
+	return 8
+}
+
+func paletteBitDepth(entries int) uint8 {
+	for _, bitDepth := range []uint8{1, 2, 4} {
+		if entries <= 1<<bitDepth {
+			return bitDepth
+		}
+	}
+	return 8
+}
+
+// reducedPng is a color type and bit depth an image can be written with, its
+// pixels are converted a row at a time while encoding
+type reducedPng struct {
+	header      IHDRData
+	palette     []TruecolorPixel
+	indices     map[color.NRGBA64]uint
+	transparent *TruecolorPixel
+}
+
+// newReducedPng writes img as colorType, pixels of the transparent color are
+// written as it and left to a tRNS chunk
+func newReducedPng(img *image.NRGBA64, colorType ColorType, bitDepth uint8, palette []color.NRGBA64, transparent *color.NRGBA64) *reducedPng {
+	p := &reducedPng{
+		header: IHDRData{
+			Width:     img.Rect.Dx(),
+			Height:    img.Rect.Dy(),
+			BitDepth:  bitDepth,
+			ColorType: colorType,
+		},
+		indices: make(map[color.NRGBA64]uint, len(palette)),
+	}
+	for i, c := range palette {
+		p.indices[c] = uint(i)
+		p.palette = append(p.palette, TruecolorPixel{Red: uint(c.R >> 8), Green: uint(c.G >> 8), Blue: uint(c.B >> 8), Alpha: uint(c.A >> 8)})
+	}
+	if transparent != nil {
+		p.transparent = &TruecolorPixel{Red: p.sample(transparent.R), Green: p.sample(transparent.G), Blue: p.sample(transparent.B)}
+	}
+	return p
+}
+
+func (p *reducedPng) sample(v uint16) uint {
+	return ScaleSample(uint(v), 16, p.header.BitDepth)
+}
+
+// encode writes img with the color type and bit depth of p, holding a single
+// scanline at a time
+func (p *reducedPng) encode(w io.Writer, img *image.NRGBA64, opts EncodeOptions, beforePLTE, afterPLTE []*Chunk) error {
+	if p.transparent != nil {
+		trns, err := encodeTransparentColor(p.header, *p.transparent)
+		if err != nil {
+			return err
+		}
+		afterPLTE = append([]*Chunk{{chunkType: TRNS, data: trns}}, afterPLTE...)
+	}
+	e, err := newRowEncoder(w, p.header, p.palette, opts, beforePLTE, afterPLTE)
+	if err != nil {
+		return err
+	}
+	row := RowBuffer{header: p.header, data: make([]byte, p.header.scanlineByteSize())}
+	for y := 0; y < p.header.Height; y++ {
+		for x := 0; x < p.header.Width; x++ {
+			c := nrgba64FromPix(img.Pix[img.PixOffset(x, y):])
+			switch p.header.ColorType {
+			case ColorTypeGrayscale:
+				row.SetSample(x, 0, p.sample(c.R))
+			case ColorTypeGrayscaleAlpha:
+				row.SetSample(x, 0, p.sample(c.R))
+				row.SetSample(x, 1, p.sample(c.A))
+			case ColorTypePalette:
+				row.SetSample(x, 0, p.indices[c])
+			case ColorTypeTruecolor, ColorTypeTruecolorAlpha:
+				row.SetSample(x, 0, p.sample(c.R))
+				row.SetSample(x, 1, p.sample(c.G))
+				row.SetSample(x, 2, p.sample(c.B))
+				if p.header.ColorType == ColorTypeTruecolorAlpha {
+					row.SetSample(x, 3, p.sample(c.A))
+				}
+			}
+		}
+		if err := e.WriteRow(row.data); err != nil {
+			return err
+		}
+	}
+	return e.Close()
+}
+
+// readAncillaryChunks returns every chunk other than IHDR, PLTE, tRNS, IDAT and
+// IEND, in order. Transparency is written again from the pixels, ToNRGBA64
+// already applied it.
+func readAncillaryChunks(data []byte) ([]*Chunk, error) {
+	read, err := readSig(data)
+	if err != nil {
+		return nil, err
+	}
+	data = data[read:]
+
+	chunks := make([]*Chunk, 0)
+	for len(data) > 0 {
+		chunk, read, err := readChunk(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[read:]
+		switch chunk.chunkType {
+		case IHDR, PLTE, TRNS, IDAT, IEND:
+		default:
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, nil
+}
+
+// keptChunks splits the chunks to keep into the ones written before and after PLTE
+func keptChunks(chunks []*Chunk, keep []ChunkType, formatChanged bool) ([]*Chunk, []*Chunk, []ChunkType) {
+	before := make([]*Chunk, 0)
+	after := make([]*Chunk, 0)
+	dropped := make([]ChunkType, 0)
+	for _, chunk := range chunks {
+		if !slices.Contains(keep, chunk.chunkType) {
+			continue
+		}
+		if formatChanged && slices.Contains(colorChunks, chunk.chunkType) {
+			dropped = append(dropped, chunk.chunkType)
+			continue
+		}
+		if slices.Contains(beforePLTEChunks, chunk.chunkType) {
+			before = append(before, chunk)
+		} else {
+			after = append(after, chunk)
+		}
+	}
+	return before, after, dropped
+}
@@ -0,0 +1,160 @@
+package png
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func testMazeImage(wall, open color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 41, 41))
+	for y := 0; y < 41; y++ {
+		for x := 0; x < 41; x++ {
+			c := open
+			if x%2 == 0 || (y%2 == 0 && (x*7+y*3)%5 != 0) {
+				c = wall
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func chunkTypes(t *testing.T, data []byte) []ChunkType {
+	t.Helper()
+	chunks, err := readAncillaryChunks(data)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	types := make([]ChunkType, 0)
+	for _, chunk := range chunks {
+		types = append(types, chunk.chunkType)
+	}
+	return types
+}
+
+func TestOptimizeTwoColorMaze(t *testing.T) {
+	tests := []struct {
+		name      string
+		wall      color.Color
+		colorType ColorType
+	}{
+		{"black and white", color.Black, ColorTypeGrayscale},
+		{"red and white", color.RGBA{R: 0xFF, A: 0xFF}, ColorTypePalette},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := encodeTestImage(t, testMazeImage(test.wall, color.White))
+
+			res, err := Optimize(data, OptimizeOptions{})
+			if err != nil {
+				t.Fatalf("Expected no error, but got: %v", err)
+			}
+			if res.ColorType != test.colorType || res.BitDepth != 1 {
+				t.Fatalf("Expected color type %d with 1 bit depth, but got: color type %d bit depth %d", test.colorType, res.ColorType, res.BitDepth)
+			}
+			if len(res.Data) >= len(data) {
+				t.Fatalf("Expected optimized image to be smaller than %d bytes, but got: %d", len(data), len(res.Data))
+			}
+			compareWithImagePng(t, res.Data)
+		})
+	}
+}
+
+func TestOptimizeReduces16BitDepth(t *testing.T) {
+	img := image.NewNRGBA64(image.Rect(0, 0, 9, 7))
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 9; x++ {
+			v := uint16(x*25+y*3) * 0x101
+			img.SetNRGBA64(x, y, color.NRGBA64{R: v, G: 0xFFFF - v, B: v / 2 / 0x101 * 0x101, A: 0xFFFF})
+		}
+	}
+	res, err := Optimize(encodeTestImage(t, img), OptimizeOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if res.BitDepth != 8 {
+		t.Fatalf("Expected 8 bit depth, but got: %d", res.BitDepth)
+	}
+}
+
+func TestOptimizeKeepsChosenChunks(t *testing.T) {
+	data := encodeTestImage(t, testMazeImage(color.Black, color.White))
+	// splice a couple of ancillary chunks in after IHDR
+	var buf bytes.Buffer
+	buf.Write(data[:33])
+	if err := writeChunk(&buf, "tEXt", []byte("Title\x00maze")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeChunk(&buf, "bKGD", []byte{0, 0, 0, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeChunk(&buf, "pHYs", []byte{0, 0, 0x0B, 0x13, 0, 0, 0x0B, 0x13, 1}); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(data[33:])
+
+	res, err := Optimize(buf.Bytes(), OptimizeOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if types := chunkTypes(t, res.Data); len(types) != 0 {
+		t.Fatalf("Expected ancillary chunks to be stripped, but got: %v", types)
+	}
+
+	res, err = Optimize(buf.Bytes(), OptimizeOptions{KeepChunks: []ChunkType{"tEXt", "bKGD"}})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if types := chunkTypes(t, res.Data); !slices.Equal(types, []ChunkType{"tEXt"}) {
+		t.Fatalf("Expected only tEXt to be kept, but got: %v", types)
+	}
+	if !slices.Equal(res.DroppedChunks, []ChunkType{"bKGD"}) {
+		t.Fatalf("Expected bKGD to be dropped for the new color type, but got: %v", res.DroppedChunks)
+	}
+}
+
+func TestOptimizeKeepsColorKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		opaque    func(x, y int) color.NRGBA
+		key       color.NRGBA
+		colorType ColorType
+	}{
+		{"grayscale", func(x, y int) color.NRGBA {
+			v := uint8(1 + (x+y*16)%255)
+			return color.NRGBA{R: v, G: v, B: v, A: 0xFF}
+		}, color.NRGBA{}, ColorTypeGrayscale},
+		{"truecolor", func(x, y int) color.NRGBA {
+			return color.NRGBA{R: uint8(x * 16), G: uint8(y * 16), B: uint8(x*y + 5), A: 0xFF}
+		}, color.NRGBA{R: 0, G: 0, B: 3}, ColorTypeTruecolor},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 16, 17))
+			for y := 0; y < 17; y++ {
+				for x := 0; x < 16; x++ {
+					c := test.opaque(x, y)
+					if (x+y)%3 == 0 {
+						c = test.key
+					}
+					img.SetNRGBA(x, y, c)
+				}
+			}
+			data := encodeTestImage(t, img)
+
+			res, err := Optimize(data, OptimizeOptions{})
+			if err != nil {
+				t.Fatalf("Expected no error, but got: %v", err)
+			}
+			if res.ColorType != test.colorType {
+				t.Fatalf("Expected color type %d, but got: %d", test.colorType, res.ColorType)
+			}
+			if decoded := compareWithImagePng(t, res.Data); decoded.Transparent == nil {
+				t.Fatalf("Expected a tRNS color key, but got none")
+			}
+		})
+	}
+}
@@ -3,15 +3,15 @@ package png
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
-	"log"
 	"math"
 	"runtime"
-	"slices"
+	"sync"
 )
 
 const FILE_SIGN = 0x89504E470D0A1A0A
@@ -39,6 +39,13 @@ type GreyscalePixel struct {
 
 func (p *GreyscalePixel) pixel() {}
 
+type GreyscaleAlphaPixel struct {
+	Value uint
+	Alpha uint
+}
+
+func (p *GreyscaleAlphaPixel) pixel() {}
+
 type TruecolorPixel struct {
 	Red   uint
 	Green uint
@@ -69,6 +76,11 @@ type Png struct {
 
 	Pixels      [][]Pixel
 	PlteEntries []TruecolorPixel
+	// Transparent is the color of the tRNS chunk of grayscale and truecolor
+	// images, nil without one. Pixels of that color are fully transparent.
+	// Its samples are at the bit depth of the image, grayscale keys have the
+	// same value in Red, Green and Blue.
+	Transparent *TruecolorPixel
 }
 
 const (
@@ -76,6 +88,7 @@ const (
 	IEND ChunkType = "IEND"
 	PLTE ChunkType = "PLTE"
 	IDAT ChunkType = "IDAT"
+	TRNS ChunkType = "tRNS"
 )
 
 type ColorType int
@@ -88,6 +101,22 @@ const (
 	ColorTypeTruecolorAlpha
 )
 
+func (c ColorType) String() string {
+	switch c {
+	case ColorTypeGrayscale:
+		return "grayscale"
+	case ColorTypeTruecolor:
+		return "truecolor"
+	case ColorTypePalette:
+		return "palette"
+	case ColorTypeGrayscaleAlpha:
+		return "grayscale with alpha"
+	case ColorTypeTruecolorAlpha:
+		return "truecolor with alpha"
+	}
+	return fmt.Sprintf("ColorType(%d)", int(c))
+}
+
 type InterlaceMethod int
 
 const (
@@ -104,10 +133,6 @@ type Scanline struct {
 	filterType FilterType
 }
 
-func (sl Scanline) unfilteringRequiresPrevScanline() bool {
-	return sl.filterType >= 2
-}
-
 const (
 	FilterTypeNone FilterType = iota
 	FilterTypeSub
@@ -116,7 +141,39 @@ const (
 	FilterTypePaeth
 )
 
+func (f FilterType) String() string {
+	switch f {
+	case FilterTypeNone:
+		return "none"
+	case FilterTypeSub:
+		return "sub"
+	case FilterTypeUp:
+		return "up"
+	case FilterTypeAverage:
+		return "average"
+	case FilterTypePaeth:
+		return "paeth"
+	}
+	return fmt.Sprintf("FilterType(%d)", int(f))
+}
+
+type DecodeOptions struct {
+	// Workers caps the number of goroutines converting scanlines into pixels,
+	// 0 picks one based on the image height and the number of CPUs.
+	Workers int
+}
+
 func DecodePng(data []byte) (*Png, error) {
+	return DecodeContext(context.Background(), data, DecodeOptions{})
+}
+
+// DecodeContext is DecodePng with control over concurrency, it stops decoding
+// as soon as ctx is done and returns ctx.Err().
+func DecodeContext(ctx context.Context, data []byte, opts DecodeOptions) (*Png, error) {
+	if opts.Workers < 0 {
+		return nil, fmt.Errorf("invalid number of workers: %d", opts.Workers)
+	}
+
 	read, err := readSig(data)
 	if err != nil {
 		return nil, err
@@ -154,8 +211,15 @@ func DecodePng(data []byte) (*Png, error) {
 				return nil, fmt.Errorf("palette color type missing PLTR chunk")
 			}
 
-			uncompressedIdatData := uncompressIDATData(idatData)
-			pixels, err := processIDATData(uncompressedIdatData, ihdrData)
+			uncompressedIdatData, err := uncompressIDATData(ctx, idatData)
+			if err != nil {
+				return nil, err
+			}
+			numWorkers := opts.Workers
+			if numWorkers == 0 {
+				numWorkers = defaultNumWorkers(ihdrData.Height)
+			}
+			pixels, err := processIDATData(ctx, uncompressedIdatData, ihdrData, numWorkers)
 			if err != nil {
 				return nil, err
 			}
@@ -182,6 +246,13 @@ func DecodePng(data []byte) (*Png, error) {
 				return nil, err
 			}
 			plteData = res
+		case TRNS:
+			res, transparent, err := decodeTRNSChunk(ihdrData, plteData, chunk.data)
+			if err != nil {
+				return nil, err
+			}
+			plteData = res
+			png.Transparent = transparent
 		case IDAT:
 			idatData = append(idatData, chunk.data...)
 		}
@@ -190,18 +261,34 @@ func DecodePng(data []byte) (*Png, error) {
 	return png, nil
 }
 
-func uncompressIDATData(data []byte) []byte {
+func uncompressIDATData(ctx context.Context, data []byte) ([]byte, error) {
 	r, err := zlib.NewReader(bytes.NewReader(data))
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("couldn't uncompress IDAT data: %w", err)
 	}
+	defer r.Close()
 	var out bytes.Buffer
-	_, err = io.Copy(&out, r)
+	_, err = io.Copy(&out, contextReader{ctx: ctx, r: r})
 	if err != nil {
-		log.Fatal(err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("couldn't uncompress IDAT data: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// contextReader fails reads once ctx is done, so long copies can be abandoned
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
-	r.Close()
-	return out.Bytes()
+	return r.r.Read(p)
 }
 
 func (h IHDRData) pixelNumChannels() int {
@@ -230,47 +317,63 @@ func (h IHDRData) scanlineBitSize() int {
 	return h.pixelBitSize() * h.Width
 }
 func (h IHDRData) scanlineBitPadding() int {
-	return h.scanlineBitSize() % 8
+	return (8 - h.scanlineBitSize()%8) % 8
 }
 func (h IHDRData) scanlineByteSize() int {
 	return (h.scanlineBitSize() + h.scanlineBitPadding()) / 8
 }
 
-func processIDATData(idatData []byte, header IHDRData) ([][]Pixel, error) {
+func processIDATData(ctx context.Context, idatData []byte, header IHDRData, numWorkers int) ([][]Pixel, error) {
+	var scanlines []*Scanline
+	var read int
+	var err error
+	if header.InterlaceMethod == InterlaceMethodAdam7 {
+		scanlines, read, err = deinterlaceAdam7(ctx, idatData, header)
+	} else {
+		scanlines, read, err = unfilterScanlines(ctx, idatData, header)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(idatData[read:]) > 0 {
+		return nil, fmt.Errorf("there was idatData left in the IDAT chunks after reading all scanlines, remaining bytes: %d", len(idatData[read:]))
+	}
+
 	pixels := make([][]Pixel, header.Height)
+	scanlineCount := header.Height
+	numWorkers = maxInt(1, minInt(numWorkers, scanlineCount))
+	scanlinesPerWorker := scanlineCount / numWorkers
 
-	pixelNumChannels := 0
-	switch header.ColorType {
-	case ColorTypeGrayscale:
-		pixelNumChannels = 1
-	case ColorTypeTruecolor:
-		pixelNumChannels = 3
-	case ColorTypePalette:
-		pixelNumChannels = 1
-	case ColorTypeGrayscaleAlpha:
-		pixelNumChannels = 2
-	case ColorTypeTruecolorAlpha:
-		pixelNumChannels = 4
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		start := scanlinesPerWorker * i
+		end := start + scanlinesPerWorker
+		if i == numWorkers-1 {
+			end = scanlineCount
+		}
+		work := scanlines[start:end]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker(ctx, header, work, pixels)
+		}()
 	}
+	wg.Wait()
 
-	pixelBitSize := pixelNumChannels * int(header.BitDepth)
-	pixelByteSize := pixelBitSize / 8
-	scanlineBitSize := pixelBitSize * header.Width
-	scanlineBitPadding := scanlineBitSize % 8
-	scanlineByteSize := (scanlineBitSize + scanlineBitPadding) / 8
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return pixels, nil
+}
 
-	// todo: we also need to break/continue on:
-	//      Scanlines always begin on byte boundaries.  When pixels have fewer
-	//      than 8 bits and the scanline width is not evenly divisible by the
-	//      number of pixels per byte, the low-order bits in the last byte of
-	//      each scanline are wasted.  The contents of these wasted bits are
-	//      unspecified.
+// unfilterScanlines splits header.Height scanlines off the start of idatData
+// and unfilters them, returning how many bytes they took
+func unfilterScanlines(ctx context.Context, idatData []byte, header IHDRData) ([]*Scanline, int, error) {
+	pixelByteSize := header.pixelByteSize()
+	scanlineByteSize := header.scanlineByteSize()
 
 	scanlineCount := header.Height
-	minScanlinesPerWorker := 100
-	optimalNumWorkers := (scanlineCount / minScanlinesPerWorker) + 1
-	numWorkers := minInt(runtime.NumCPU(), optimalNumWorkers)
-	scanlinesPerWorker := scanlineCount / numWorkers
 
 	scanlines := make([]*Scanline, 0)
 	for i := 0; i < scanlineCount; i++ {
@@ -278,10 +381,7 @@ func processIDATData(idatData []byte, header IHDRData) ([][]Pixel, error) {
 		start := slLen * i
 		end := start + slLen
 		if end > len(idatData) {
-			return nil, fmt.Errorf("couldn't parse IDAT idatData, not enough idatData to read all scanlines, expected: %d scanlines, got: %d", header.Height, i)
-		}
-		if i == scanlineCount-1 && len(idatData[end:]) > 0 {
-			return nil, fmt.Errorf("there was idatData left in the IDAT chunks after reading all scanlines, remaining idatData: %v", idatData)
+			return nil, 0, fmt.Errorf("couldn't parse IDAT idatData, not enough idatData to read all scanlines, expected: %d scanlines, got: %d", header.Height, i)
 		}
 		unpData := idatData[start:end]
 		filterType := FilterType(unpData[0])
@@ -293,246 +393,65 @@ func processIDATData(idatData []byte, header IHDRData) ([][]Pixel, error) {
 		scanlines = append(scanlines, scanline)
 	}
 
-	results := make(chan Result, numWorkers)
-
-	for i := 0; i < numWorkers; i++ {
-		start := scanlinesPerWorker * i
-		end := start + scanlinesPerWorker
-		if i == numWorkers-1 {
-			end = scanlineCount
-		}
-		work := scanlines[start:end]
-		go worker(
-			header,
-			work,
-			scanlineByteSize,
-			pixelBitSize,
-			pixelByteSize,
-			results,
-		)
-	}
-
-	unprocessedScanlinesIndices := make([]int, 0)
-
-	for i := 0; i < numWorkers; i++ {
-		result := <-results
-		if result.err != nil {
-			return nil, result.err
-		}
-		unprocessedScanlinesIndices = append(unprocessedScanlinesIndices, result.unprocessedIndices...)
-		for _, sl := range result.scanlines {
-			pixels[sl.index] = sl.pixels
-		}
-
-		if i == numWorkers-1 {
-			close(results)
+	// Up, Average and Paeth need the previous scanline already unfiltered, so a
+	// chain of them can span any number of rows. Unfiltering is only byte
+	// arithmetic and is done in a single ordered pass, the per-row conversion to
+	// pixels is what gets split between the workers.
+	prevScanline := &Scanline{data: make([]byte, scanlineByteSize)}
+	for _, sl := range scanlines {
+		if sl.index%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
 		}
-	}
-
-	slices.Sort(unprocessedScanlinesIndices)
-
-	for _, index := range unprocessedScanlinesIndices {
-		sl := scanlines[index]
-
-		res, err := processScanline(
-			header,
-			scanlines[index-1],
+		err := unfilterScanline(
+			prevScanline,
 			sl,
 			scanlineByteSize,
-			pixelBitSize,
 			pixelByteSize,
 		)
-
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-
-		pixels[index] = res
+		prevScanline = sl
 	}
 
-	return pixels, nil
+	return scanlines, scanlineCount * (scanlineByteSize + 1), nil
 }
 
-type ScanlineResult struct {
-	index  int
-	pixels []Pixel
-}
+// how many scanlines are processed between checks for cancellation
+const ctxCheckInterval = 64
 
-type Result struct {
-	unprocessedIndices []int
-	scanlines          []ScanlineResult
-	err                error
+func defaultNumWorkers(scanlineCount int) int {
+	minScanlinesPerWorker := 100
+	optimalNumWorkers := (scanlineCount / minScanlinesPerWorker) + 1
+	return minInt(runtime.NumCPU(), optimalNumWorkers)
 }
 
 func worker(
+	ctx context.Context,
 	header IHDRData,
 	scanlines []*Scanline,
-	scanlineByteSize int,
-	pixelBitSize int,
-	pixelByteSize int,
-	results chan<- Result,
+	pixels [][]Pixel,
 ) {
-	result := Result{}
 	for i, sl := range scanlines {
-		var prevScanline *Scanline
-		if sl.unfilteringRequiresPrevScanline() {
-			if sl.index == 0 {
-				prevScanline = &Scanline{data: make([]byte, scanlineByteSize)}
-			} else if i == 0 || len(scanlines[i-1].data) == 0 {
-				result.unprocessedIndices = append(result.unprocessedIndices, sl.index)
-				continue
-			} else {
-				prevScanline = scanlines[i-1]
-			}
-		}
-
-		pixels, err := processScanline(
-			header,
-			prevScanline,
-			sl,
-			scanlineByteSize,
-			pixelBitSize,
-			pixelByteSize,
-		)
-
-		if err != nil {
-			result.err = err
-			results <- result
+		if i%ctxCheckInterval == 0 && ctx.Err() != nil {
 			return
 		}
-
-		scanlineResult := ScanlineResult{index: sl.index, pixels: pixels}
-		result.scanlines = append(result.scanlines, scanlineResult)
+		pixels[sl.index] = processScanline(header, sl)
 	}
-	results <- result
 }
 
 func processScanline(
 	header IHDRData,
-	prevScanline *Scanline,
 	scanline *Scanline,
-	scanlineByteSize int,
-	pixelBitSize int,
-	pixelByteSize int,
-) ([]Pixel, error) {
-	// also strips filter type byte
-	err := unfilterScanline(
-		prevScanline,
-		scanline,
-		scanlineByteSize,
-		pixelByteSize,
-	)
-	if err != nil {
-		return nil, err
-	}
-	data := scanline.data
-
+) []Pixel {
+	row := RowView{header: header, data: scanline.data}
 	pixels := make([]Pixel, header.Width)
-	for x := 0; x < header.Width; x++ {
-		var smallPixelData uint
-		if pixelBitSize < 8 {
-			currDataByteIndex := (x * pixelBitSize) / 8
-			smallPixelData = uint(data[currDataByteIndex])
-		}
-
-		var pixel Pixel
-		switch header.ColorType {
-		case ColorTypeGrayscale:
-			var value uint
-			switch pixelByteSize {
-			case 0:
-				// in pixels smaller than a byte, this is used to place the mask correctly within the byte to get the correct pixel
-				// so if pixelBitSize == 2, we want a mask of 0b11000000, for the first pixel in the byte, 0b00110000 for the second, etc..
-				pixelsPerByte := 8 / pixelBitSize
-				// pixelsperbyte == 8 && x == 0, shiftMaskBy = 7 0b1000 0000
-				// pixelsperbyte == 8 && x == 1, shiftMaskBy = 6 0b0100 0000
-				// pixelsperbyte == 4 && x == 0, shiftMaskBy = 6 0b1100 0000
-				// pixelsperbyte == 4 && x == 2, shiftMaskBy = 2 0b0000 1100
-				// pixelsperbyte == 4 && x == 3, shiftMaskBy = 0 0b0000 0011
-				// pixelsperbyte == 2 && x == 0, shiftMaskBy = 4 0b1111 0000
-				// pixelsperbyte == 2 && x == 1, shiftMaskBy = 0 0b1111 0000
-				shiftMaskBy := (8 / pixelsPerByte) * (pixelsPerByte - 1 - x)
-				mask := uint((1<<pixelBitSize)-1) << shiftMaskBy
-				value = smallPixelData & uint(mask)
-			case 1:
-				value = uint(data[0])
-			case 2:
-				value = uint(binary.BigEndian.Uint16(data))
-			}
-			pixel = &GreyscalePixel{
-				Value: value,
-			}
-		case ColorTypeTruecolor:
-			switch header.BitDepth {
-			case 8:
-				pixel = &TruecolorPixel{
-					Red:   uint(data[0]),
-					Green: uint(data[1]),
-					Blue:  uint(data[2]),
-					Alpha: math.MaxUint,
-				}
-			case 16:
-				pixel = &TruecolorPixel{
-					Red:   uint(binary.BigEndian.Uint16(data)),
-					Green: uint(binary.BigEndian.Uint16(data[2:])),
-					Blue:  uint(binary.BigEndian.Uint16(data[4:])),
-					Alpha: math.MaxUint,
-				}
-			}
-		case ColorTypePalette:
-			var value uint
-			if pixelBitSize < 8 {
-				// in pixels smaller than a byte, this is used to place the mask correctly within the byte to get the correct pixel
-				// so if pixelBitSize == 2, we want a mask of 0b11000000, for the first pixel in the byte, 0b00110000 for the second, etc..
-				pixelsPerByte := 8 / pixelBitSize
-				// pixelsperbyte == 8 && x == 0, shiftMaskBy = 7 0b1000 0000
-				// pixelsperbyte == 8 && x == 1, shiftMaskBy = 6 0b0100 0000
-				// pixelsperbyte == 4 && x == 0, shiftMaskBy = 6 0b1100 0000
-				// pixelsperbyte == 4 && x == 2, shiftMaskBy = 2 0b0000 1100
-				// pixelsperbyte == 4 && x == 3, shiftMaskBy = 0 0b0000 0011
-				// pixelsperbyte == 2 && x == 0, shiftMaskBy = 4 0b1111 0000
-				// pixelsperbyte == 2 && x == 1, shiftMaskBy = 0 0b1111 0000
-				shiftMaskBy := (8 / pixelsPerByte) * (pixelsPerByte - 1 - x)
-				mask := uint((1<<pixelBitSize)-1) << shiftMaskBy
-				value = smallPixelData & uint(mask)
-			} else {
-				value = uint(data[0])
-			}
-			pixel = &PalettePixel{
-				Index: value,
-			}
-		case ColorTypeGrayscaleAlpha:
-		case ColorTypeTruecolorAlpha:
-			switch header.BitDepth {
-			case 8:
-				pixel = &TruecolorPixel{
-					Red:   uint(data[0]),
-					Green: uint(data[1]),
-					Blue:  uint(data[2]),
-					Alpha: uint(data[3]),
-				}
-			case 16:
-				pixel = &TruecolorPixel{
-					Red:   uint(binary.BigEndian.Uint16(data)),
-					Green: uint(binary.BigEndian.Uint16(data[2:])),
-					Blue:  uint(binary.BigEndian.Uint16(data[4:])),
-					Alpha: uint(binary.BigEndian.Uint16(data[6:])),
-				}
-			}
-		}
-
-		if pixelBitSize < 8 {
-			pixelsPerByte := 8 / pixelBitSize
-			if x%pixelsPerByte == pixelsPerByte-1 {
-				data = data[1:]
-			}
-		} else {
-			data = data[pixelByteSize:]
-		}
-		pixels[x] = pixel
+	for x := range pixels {
+		pixels[x] = row.Pixel(x)
 	}
-
-	return pixels, nil
+	return pixels
 }
 
 func unfilterScanline(
@@ -545,11 +464,16 @@ func unfilterScanline(
 		pixelByteSize = 1
 	}
 
-	result := make([]byte, scanlineByteSize)
+	// rows decoded one at a time hand in their previous buffer to be reused
+	result := scanline.data
+	if cap(result) < scanlineByteSize {
+		result = make([]byte, scanlineByteSize)
+	}
+	result = result[:scanlineByteSize]
 
 	switch scanline.filterType {
 	case FilterTypeNone:
-		scanline.data = scanline.unfData
+		copy(result, scanline.unfData)
 	case FilterTypeSub:
 		for i := 0; i < scanlineByteSize; i++ {
 			subX := uint(scanline.unfData[i])
@@ -644,6 +568,13 @@ func minInt(a, b int) int {
 	return b
 }
 
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func decodeIHDRChunk(data []byte) (IHDRData, error) {
 	res := IHDRData{}
 	if len(data) != 13 {
@@ -732,6 +663,39 @@ func decodePLTEChunk(ihdrData IHDRData, data []byte) (PLTEData, error) {
 	return res, nil
 }
 
+// decodeTRNSChunk applies the alpha of palette entries, or returns the
+// transparent color of greyscale and truecolor images
+func decodeTRNSChunk(ihdrData IHDRData, plteData PLTEData, data []byte) (PLTEData, *TruecolorPixel, error) {
+	// only the low bits of the samples count for bit depths under 16
+	mask := uint(1)<<ihdrData.BitDepth - 1
+	switch ihdrData.ColorType {
+	case ColorTypePalette:
+		if len(data) > len(plteData.Entries) {
+			return plteData, nil, fmt.Errorf("invalid tRNS chunk data, palette has %d entries, found: %d alpha values", len(plteData.Entries), len(data))
+		}
+		for i, alpha := range data {
+			plteData.Entries[i].Alpha = uint(alpha)
+		}
+		return plteData, nil, nil
+	case ColorTypeGrayscale:
+		if len(data) != 2 {
+			return plteData, nil, fmt.Errorf("invalid tRNS chunk data, expected 2 bytes for a grayscale image, found: %d", len(data))
+		}
+		v := uint(binary.BigEndian.Uint16(data)) & mask
+		return plteData, &TruecolorPixel{Red: v, Green: v, Blue: v}, nil
+	case ColorTypeTruecolor:
+		if len(data) != 6 {
+			return plteData, nil, fmt.Errorf("invalid tRNS chunk data, expected 6 bytes for a truecolor image, found: %d", len(data))
+		}
+		return plteData, &TruecolorPixel{
+			Red:   uint(binary.BigEndian.Uint16(data)) & mask,
+			Green: uint(binary.BigEndian.Uint16(data[2:])) & mask,
+			Blue:  uint(binary.BigEndian.Uint16(data[4:])) & mask,
+		}, nil
+	}
+	return plteData, nil, fmt.Errorf("invalid tRNS chunk, images of color type %s already have an alpha channel", ihdrData.ColorType)
+}
+
 func readSig(data []byte) (int, error) {
 	sig := data[:8]
 
@@ -1,6 +1,13 @@
 package png
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
 
 var PNG_SIGN = []byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a}
 var REAL_PNG = []byte{
@@ -74,3 +81,87 @@ func TestDecodeChunkWithRealIHDR(t *testing.T) {
 		t.Fatalf("Expected read count to be %d, but got: %d", len(REAL_IHDR_CHUNK), read)
 	}
 }
+
+// filterScanlines applies the given filter to every raw scanline with the
+// encoder's filters, producing idat data as it would be before compression
+func filterScanlines(rows [][]byte, filters []FilterType, bpp int) []byte {
+	out := make([]byte, 0)
+	prior := make([]byte, len(rows[0]))
+	for y, row := range rows {
+		filtered := make([]byte, len(row))
+		filterScanline(filters[y], filtered, row, prior, bpp)
+		out = append(out, byte(filters[y]))
+		out = append(out, filtered...)
+		prior = row
+	}
+	return out
+}
+
+func TestUnfilteringIndependentOfWorkerCount(t *testing.T) {
+	header := IHDRData{Width: 13, Height: 211, BitDepth: 8, ColorType: ColorTypeTruecolorAlpha}
+	rows := make([][]byte, header.Height)
+	filters := make([]FilterType, header.Height)
+	for y := range rows {
+		rows[y] = make([]byte, header.Width*4)
+		for i := range rows[y] {
+			rows[y][i] = byte(y*31 + i*7 + (y*i)%13)
+		}
+		// long runs of rows depending on the previous one, broken by the odd None/Sub row
+		switch {
+		case y%53 == 0:
+			filters[y] = FilterTypeNone
+		case y%41 == 0:
+			filters[y] = FilterTypeSub
+		default:
+			filters[y] = []FilterType{FilterTypeUp, FilterTypeAverage, FilterTypePaeth}[(y/7)%3]
+		}
+	}
+	idatData := filterScanlines(rows, filters, 4)
+
+	for numWorkers := 1; numWorkers <= 32; numWorkers++ {
+		pixels, err := processIDATData(context.Background(), slices.Clone(idatData), header, numWorkers)
+		if err != nil {
+			t.Fatalf("workers %d: expected no error, but got: %v", numWorkers, err)
+		}
+		for y, row := range rows {
+			for x := 0; x < header.Width; x++ {
+				p := pixels[y][x].(*TruecolorPixel)
+				got := []byte{byte(p.Red), byte(p.Green), byte(p.Blue), byte(p.Alpha)}
+				if !bytes.Equal(got, row[x*4:x*4+4]) {
+					t.Fatalf("workers %d: pixel (%d, %d) expected %v, but got: %v", numWorkers, x, y, row[x*4:x*4+4], got)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeContextWithWorkers(t *testing.T) {
+	expected, err := DecodePng(REAL_PNG)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	for _, workers := range []int{1, 2, 5, 8} {
+		res, err := DecodeContext(context.Background(), REAL_PNG, DecodeOptions{Workers: workers})
+		if err != nil {
+			t.Fatalf("workers %d: expected no error, but got: %v", workers, err)
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Fatalf("workers %d: decoded png differs from the default decoding", workers)
+		}
+	}
+
+	_, err = DecodeContext(context.Background(), REAL_PNG, DecodeOptions{Workers: -1})
+	if err == nil {
+		t.Fatal("Expected error due to negative workers, but got no error")
+	}
+}
+
+func TestDecodeContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := DecodeContext(ctx, REAL_PNG, DecodeOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, but got: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package png
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+type RegionOptions struct {
+	// Rect is the part of the image to decode, in full resolution pixel
+	// coordinates. The zero value decodes the whole image.
+	Rect image.Rectangle
+	// Scale downsamples the region by averaging every Scale x Scale box of
+	// pixels into one, 0 and 1 keep the full resolution.
+	Scale int
+}
+
+var errRegionDecoded = errors.New("region decoded")
+
+// DecodeRegion streams the png in r and keeps only the pixels in opts.Rect,
+// box filtered down by opts.Scale. Rows after the region aren't decoded.
+func DecodeRegion(r io.Reader, opts RegionOptions) (*image.RGBA, error) {
+	if opts.Scale < 0 {
+		return nil, fmt.Errorf("invalid region scale: %d", opts.Scale)
+	}
+	scale := maxInt(opts.Scale, 1)
+
+	var img *image.RGBA
+	var rect image.Rectangle
+	// premultiplied 16 bit channel sums of the boxes in the current output row
+	var sums []uint64
+
+	err := DecodeRows(r, func(y int, row RowView) error {
+		if img == nil {
+			bounds := image.Rect(0, 0, row.Width(), row.Height())
+			rect = bounds
+			if !opts.Rect.Empty() {
+				rect = opts.Rect.Intersect(bounds)
+				if rect.Empty() {
+					return fmt.Errorf("region %v is outside of the image bounds %v", opts.Rect, bounds)
+				}
+			}
+			img = image.NewRGBA(image.Rect(0, 0, ceilDiv(rect.Dx(), scale), ceilDiv(rect.Dy(), scale)))
+			sums = make([]uint64, img.Rect.Dx()*4)
+		}
+		if y < rect.Min.Y {
+			return nil
+		}
+		if y >= rect.Max.Y {
+			return errRegionDecoded
+		}
+
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r, g, b, a, err := rowRGBA64(row, x)
+			if err != nil {
+				return fmt.Errorf("couldn't convert pixel (%d, %d): %w", x, y, err)
+			}
+			i := (x - rect.Min.X) / scale * 4
+			sums[i] += uint64(r * a / 0xFFFF)
+			sums[i+1] += uint64(g * a / 0xFFFF)
+			sums[i+2] += uint64(b * a / 0xFFFF)
+			sums[i+3] += uint64(a)
+		}
+
+		boxRows := (y-rect.Min.Y)%scale + 1
+		if boxRows < scale && y < rect.Max.Y-1 {
+			return nil
+		}
+		pix := img.Pix[(y-rect.Min.Y)/scale*img.Stride:]
+		for i := range sums {
+			boxCols := minInt(scale, rect.Max.X-rect.Min.X-i/4*scale)
+			n := uint64(boxRows * boxCols)
+			pix[i] = uint8((sums[i]/n*0xFF + 0x7FFF) / 0xFFFF)
+			sums[i] = 0
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRegionDecoded) {
+		return nil, err
+	}
+	if img == nil {
+		return image.NewRGBA(image.Rectangle{}), nil
+	}
+
+	return img, nil
+}
+
+// rowRGBA64 is the non premultiplied color of the pixel at x, scaled to 16 bits
+func rowRGBA64(row RowView, x int) (r, g, b, a uint32, err error) {
+	bitDepth := row.BitDepth()
+	sample := func(channel int) uint32 {
+		return uint32(ScaleSample(row.Sample(x, channel), bitDepth, 16))
+	}
+
+	switch row.ColorType() {
+	case ColorTypeGrayscale:
+		v := sample(0)
+		return v, v, v, opaque(row, x), nil
+	case ColorTypeGrayscaleAlpha:
+		v := sample(0)
+		return v, v, v, sample(1), nil
+	case ColorTypeTruecolor:
+		return sample(0), sample(1), sample(2), opaque(row, x), nil
+	case ColorTypeTruecolorAlpha:
+		return sample(0), sample(1), sample(2), sample(3), nil
+	case ColorTypePalette:
+		c, err := ToTruecolor(&PalettePixel{Index: row.Sample(x, 0)}, bitDepth, row.Palette())
+		if err != nil {
+			return 0, 0, 0, 0, err
+		}
+		c = To16Bit(c)
+		return uint32(c.Red), uint32(c.Green), uint32(c.Blue), uint32(c.Alpha), nil
+	}
+	return 0, 0, 0, 0, fmt.Errorf("unknown color type %v", row.ColorType())
+}
+
+// opaque is the alpha of a pixel without an alpha channel, 0 when it has the
+// tRNS color
+func opaque(row RowView, x int) uint32 {
+	if row.IsTransparent(x) {
+		return 0
+	}
+	return 0xFFFF
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
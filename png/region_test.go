@@ -0,0 +1,142 @@
+package png
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	stdpng "image/png"
+	"testing"
+)
+
+func encodeTestImage(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := stdpng.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testRegionImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 23, 17))
+	for y := 0; y < 17; y++ {
+		for x := 0; x < 23; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 11), G: uint8(y * 15), B: uint8(x * y), A: uint8(255 - x*y%64)})
+		}
+	}
+	return img
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestDecodeRegionCrop(t *testing.T) {
+	src := testRegionImage()
+	rect := image.Rect(3, 5, 20, 11)
+
+	img, err := DecodeRegion(bytes.NewReader(encodeTestImage(t, src)), RegionOptions{Rect: rect})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if img.Rect.Dx() != rect.Dx() || img.Rect.Dy() != rect.Dy() {
+		t.Fatalf("Expected size %v, but got: %v", rect.Size(), img.Rect.Size())
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			expected := color.RGBAModel.Convert(src.At(x, y)).(color.RGBA)
+			got := img.RGBAAt(x-rect.Min.X, y-rect.Min.Y)
+			if absDiff(expected.R, got.R) > 1 || absDiff(expected.G, got.G) > 1 || absDiff(expected.B, got.B) > 1 || expected.A != got.A {
+				t.Fatalf("pixel (%d, %d) expected %v, but got: %v", x, y, expected, got)
+			}
+		}
+	}
+}
+
+func TestDecodeRegionScaled(t *testing.T) {
+	src := testRegionImage()
+	scale := 4
+
+	img, err := DecodeRegion(bytes.NewReader(encodeTestImage(t, src)), RegionOptions{Scale: scale})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if img.Rect.Dx() != 6 || img.Rect.Dy() != 5 {
+		t.Fatalf("Expected size 6x5, but got: %v", img.Rect.Size())
+	}
+	for oy := 0; oy < 5; oy++ {
+		for ox := 0; ox < 6; ox++ {
+			var sum [4]uint32
+			n := uint32(0)
+			for y := oy * scale; y < minInt(oy*scale+scale, 17); y++ {
+				for x := ox * scale; x < minInt(ox*scale+scale, 23); x++ {
+					r, g, b, a := src.At(x, y).RGBA()
+					sum[0] += r
+					sum[1] += g
+					sum[2] += b
+					sum[3] += a
+					n++
+				}
+			}
+			got := img.RGBAAt(ox, oy)
+			for i, v := range []uint8{got.R, got.G, got.B, got.A} {
+				if expected := uint8(sum[i] / n >> 8); absDiff(expected, v) > 1 {
+					t.Fatalf("pixel (%d, %d) channel %d expected %d, but got: %d", ox, oy, i, expected, v)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeRegionOutsideOfImage(t *testing.T) {
+	_, err := DecodeRegion(bytes.NewReader(REAL_PNG), RegionOptions{Rect: image.Rect(10, 10, 20, 20)})
+	if err == nil {
+		t.Fatal("Expected error due to region outside of the image, but got no error")
+	}
+}
+
+func TestDecodeRegionPaletteIndexOutOfRange(t *testing.T) {
+	p := &Png{
+		Width:       2,
+		Height:      1,
+		ColorType:   ColorTypePalette,
+		BitDepth:    1,
+		PlteEntries: []TruecolorPixel{{Red: 0xFF, Alpha: 0xFF}},
+		Pixels:      [][]Pixel{{&PalettePixel{Index: 0}, &PalettePixel{Index: 1}}},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, p, EncodeOptions{}); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if _, err := DecodeRegion(bytes.NewReader(buf.Bytes()), RegionOptions{}); err == nil {
+		t.Fatal("Expected error due to palette index out of range, but got no error")
+	}
+}
+
+func TestDecodeRegionColorKey(t *testing.T) {
+	p := &Png{
+		Width:       2,
+		Height:      1,
+		ColorType:   ColorTypeGrayscale,
+		BitDepth:    8,
+		Transparent: &TruecolorPixel{Red: 0x80, Green: 0x80, Blue: 0x80},
+		Pixels:      [][]Pixel{{&GreyscalePixel{Value: 0x80}, &GreyscalePixel{Value: 0x40}}},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, p, EncodeOptions{}); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	img, err := DecodeRegion(bytes.NewReader(buf.Bytes()), RegionOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{}) {
+		t.Fatalf("Expected the keyed pixel to be transparent, but got: %v", got)
+	}
+	if got := img.RGBAAt(1, 0); got != (color.RGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xFF}) {
+		t.Fatalf("Expected an opaque gray pixel, but got: %v", got)
+	}
+}
@@ -0,0 +1,212 @@
+package png
+
+import (
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RowEncoder writes a png one scanline at a time. Scanlines are filtered and
+// compressed as they come and the compressed data is written out in IDAT chunks
+// of EncodeOptions.ChunkSize, so memory use doesn't depend on the image size.
+type RowEncoder struct {
+	w      io.Writer
+	header IHDRData
+	idat   *idatWriter
+	zw     *zlib.Writer
+	filter *scanlineFilter
+	rows   int
+}
+
+// NewRowEncoder writes everything up to the image data, palette is only used
+// for palette images. Interlaced images can't be written row by row.
+func NewRowEncoder(w io.Writer, header IHDRData, palette []TruecolorPixel, opts EncodeOptions) (*RowEncoder, error) {
+	if header.InterlaceMethod != InterlaceMethodNone {
+		return nil, errors.New("RowEncoder doesn't support interlaced images")
+	}
+	return newRowEncoder(w, header, palette, opts, nil, nil)
+}
+
+func newRowEncoder(w io.Writer, header IHDRData, palette []TruecolorPixel, opts EncodeOptions, beforePLTE, afterPLTE []*Chunk) (*RowEncoder, error) {
+	ihdr := encodeIHDRChunk(header)
+	// the decoder already knows what a valid header is
+	if _, err := decodeIHDRChunk(ihdr); err != nil {
+		return nil, err
+	}
+	if header.ColorType == ColorTypePalette && (len(palette) == 0 || len(palette) > 1<<header.BitDepth) {
+		return nil, fmt.Errorf("palette should have between 1 and %d entries, has: %d", 1<<header.BitDepth, len(palette))
+	}
+	level, err := opts.CompressionLevel.zlibLevel()
+	if err != nil {
+		return nil, err
+	}
+	if opts.ChunkSize < 0 {
+		return nil, fmt.Errorf("invalid chunk size: %d", opts.ChunkSize)
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = defaultChunkSize
+	}
+	filters := opts.Filters
+	if filters == nil {
+		filters = defaultFilters(header)
+	}
+	if len(filters) == 0 {
+		return nil, errors.New("no filters to try, use nil for the default ones")
+	}
+	for _, filterType := range filters {
+		if filterType < FilterTypeNone || filterType > FilterTypePaeth {
+			return nil, fmt.Errorf("unknown filter type %v", filterType)
+		}
+	}
+
+	if _, err := w.Write(binary.BigEndian.AppendUint64(nil, FILE_SIGN)); err != nil {
+		return nil, err
+	}
+	if err := writeChunk(w, IHDR, ihdr); err != nil {
+		return nil, err
+	}
+	for _, chunk := range beforePLTE {
+		if err := writeChunk(w, chunk.chunkType, chunk.data); err != nil {
+			return nil, err
+		}
+	}
+	if header.ColorType == ColorTypePalette {
+		plte, trns := encodePLTEChunk(palette)
+		if err := writeChunk(w, PLTE, plte); err != nil {
+			return nil, err
+		}
+		if len(trns) > 0 {
+			if err := writeChunk(w, TRNS, trns); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, chunk := range afterPLTE {
+		if err := writeChunk(w, chunk.chunkType, chunk.data); err != nil {
+			return nil, err
+		}
+	}
+
+	idat := &idatWriter{w: w, buf: make([]byte, 0, chunkSize)}
+	zw, err := zlib.NewWriterLevel(idat, level)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RowEncoder{
+		w:      w,
+		header: header,
+		idat:   idat,
+		zw:     zw,
+		filter: newScanlineFilter(header, filters),
+	}, nil
+}
+
+// WriteRow writes the next scanline, in the same layout RowView.Bytes has
+func (e *RowEncoder) WriteRow(row []byte) error {
+	if e.rows == e.header.Height {
+		return fmt.Errorf("all %d rows were already written", e.header.Height)
+	}
+	if len(row) != len(e.filter.raw) {
+		return fmt.Errorf("expected row %d to be %d bytes long, was: %d", e.rows, len(e.filter.raw), len(row))
+	}
+	if err := e.writeScanline(row); err != nil {
+		return err
+	}
+	e.rows++
+	return nil
+}
+
+func (e *RowEncoder) writeScanline(raw []byte) error {
+	_, err := e.zw.Write(e.filter.filter(raw))
+	return err
+}
+
+// Close flushes the image data and ends the png, it doesn't close the underlying writer
+func (e *RowEncoder) Close() error {
+	if e.rows != e.header.Height {
+		return fmt.Errorf("expected %d rows, only %d were written", e.header.Height, e.rows)
+	}
+	if err := e.zw.Close(); err != nil {
+		return err
+	}
+	if err := e.idat.flush(); err != nil {
+		return err
+	}
+	return writeChunk(e.w, IEND, nil)
+}
+
+// RowBuffer is a zeroed scanline to be filled by the callback of EncodeRows
+type RowBuffer struct {
+	header IHDRData
+	data   []byte
+}
+
+func (r RowBuffer) Width() int {
+	return r.header.Width
+}
+
+// SetSample sets the value of a single channel of the pixel at x
+func (r RowBuffer) SetSample(x, channel int, v uint) {
+	setSample(r.data, int(r.header.BitDepth), x*r.header.pixelNumChannels()+channel, v)
+}
+
+// Bytes is the raw scanline, it can also be written directly
+func (r RowBuffer) Bytes() []byte {
+	return r.data
+}
+
+// EncodeRows writes a png whose scanlines are filled in order by fn, holding a
+// single one at a time. Encoding stops at the first error returned by fn.
+func EncodeRows(w io.Writer, header IHDRData, palette []TruecolorPixel, opts EncodeOptions, fn func(y int, row RowBuffer) error) error {
+	e, err := NewRowEncoder(w, header, palette, opts)
+	if err != nil {
+		return err
+	}
+	row := RowBuffer{header: header, data: make([]byte, header.scanlineByteSize())}
+	for y := 0; y < header.Height; y++ {
+		clear(row.data)
+		if err := fn(y, row); err != nil {
+			return err
+		}
+		if err := e.WriteRow(row.data); err != nil {
+			return err
+		}
+	}
+	return e.Close()
+}
+
+// idatWriter buffers compressed data and writes it in IDAT chunks of cap(buf) bytes
+type idatWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (w *idatWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		free := cap(w.buf) - len(w.buf)
+		if free == 0 {
+			if err := w.flush(); err != nil {
+				return n - len(p), err
+			}
+			continue
+		}
+		written := minInt(free, len(p))
+		w.buf = append(w.buf, p[:written]...)
+		p = p[written:]
+	}
+	return n, nil
+}
+
+func (w *idatWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := writeChunk(w.w, IDAT, w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
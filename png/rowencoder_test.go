@@ -0,0 +1,106 @@
+package png
+
+import (
+	"bytes"
+	"image/color"
+	stdpng "image/png"
+	"testing"
+)
+
+func TestEncodeRowsSplitsIDAT(t *testing.T) {
+	header := IHDRData{Width: 37, Height: 23, BitDepth: 1, ColorType: ColorTypeGrayscale}
+	wall := func(x, y int) bool {
+		return x%2 == 0 || (y%2 == 0 && (x+y)%3 == 0)
+	}
+
+	var buf bytes.Buffer
+	opts := EncodeOptions{ChunkSize: 16, CompressionLevel: NoCompression}
+	err := EncodeRows(&buf, header, nil, opts, func(y int, row RowBuffer) error {
+		for x := 0; x < row.Width(); x++ {
+			if !wall(x, y) {
+				row.SetSample(x, 0, 1)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	img, err := stdpng.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("image/png couldn't decode the image: %v", err)
+	}
+	for y := 0; y < header.Height; y++ {
+		for x := 0; x < header.Width; x++ {
+			expected := color.Gray{Y: 0xFF}
+			if wall(x, y) {
+				expected = color.Gray{}
+			}
+			if got := img.At(x, y); got != expected {
+				t.Fatalf("pixel (%d, %d) expected %v, but got: %v", x, y, expected, got)
+			}
+		}
+	}
+
+	data := buf.Bytes()[8:]
+	idatChunks := 0
+	for len(data) > 0 {
+		chunk, read, err := readChunk(data)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		if chunk.chunkType == IDAT {
+			idatChunks++
+			if len(chunk.data) > opts.ChunkSize {
+				t.Fatalf("Expected IDAT chunks of at most %d bytes, but got: %d", opts.ChunkSize, len(chunk.data))
+			}
+		}
+		data = data[read:]
+	}
+	// 23 rows of 6 bytes plus the filter type byte don't fit in a single chunk
+	if idatChunks < 23*7/opts.ChunkSize {
+		t.Fatalf("Expected at least %d IDAT chunks, but got: %d", 23*7/opts.ChunkSize, idatChunks)
+	}
+}
+
+func TestRowEncoderRowCount(t *testing.T) {
+	header := IHDRData{Width: 2, Height: 2, BitDepth: 8, ColorType: ColorTypeTruecolor}
+
+	e, err := NewRowEncoder(&bytes.Buffer{}, header, nil, EncodeOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if err := e.WriteRow(make([]byte, 5)); err == nil {
+		t.Fatal("Expected error due to short row, but got no error")
+	}
+	if err := e.WriteRow(make([]byte, 6)); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if err := e.Close(); err == nil {
+		t.Fatal("Expected error due to missing row, but got no error")
+	}
+	if err := e.WriteRow(make([]byte, 6)); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if err := e.WriteRow(make([]byte, 6)); err == nil {
+		t.Fatal("Expected error due to too many rows, but got no error")
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	header.InterlaceMethod = InterlaceMethodAdam7
+	if _, err := NewRowEncoder(&bytes.Buffer{}, header, nil, EncodeOptions{}); err == nil {
+		t.Fatal("Expected error due to interlaced image, but got no error")
+	}
+}
+
+func TestRowEncoderRejectsFilters(t *testing.T) {
+	header := IHDRData{Width: 2, Height: 2, BitDepth: 8, ColorType: ColorTypeTruecolor}
+	for _, filters := range [][]FilterType{{}, {FilterTypeSub, FilterTypePaeth + 1}, {-1}} {
+		if _, err := NewRowEncoder(&bytes.Buffer{}, header, nil, EncodeOptions{Filters: filters}); err == nil {
+			t.Fatalf("Expected error due to filters %v, but got no error", filters)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// RowView is a single unfiltered scanline. The bytes backing it are reused for
+// the next row, so it's only valid until the callback it was passed to returns.
+type RowView struct {
+	header      IHDRData
+	data        []byte
+	palette     []TruecolorPixel
+	transparent *TruecolorPixel
+}
+
+func (r RowView) Width() int {
+	return r.header.Width
+}
+
+func (r RowView) Height() int {
+	return r.header.Height
+}
+
+func (r RowView) ColorType() ColorType {
+	return r.header.ColorType
+}
+
+func (r RowView) BitDepth() uint8 {
+	return r.header.BitDepth
+}
+
+// Palette holds the PLTE entries for palette images, nil otherwise
+func (r RowView) Palette() []TruecolorPixel {
+	return r.palette
+}
+
+// Transparent is the color of fully transparent pixels of grayscale and
+// truecolor images with a tRNS chunk, nil otherwise
+func (r RowView) Transparent() *TruecolorPixel {
+	return r.transparent
+}
+
+// IsTransparent reports whether the pixel at x has the Transparent color,
+// which makes it fully transparent
+func (r RowView) IsTransparent(x int) bool {
+	key := r.transparent
+	switch {
+	case key == nil:
+		return false
+	case r.header.ColorType == ColorTypeGrayscale:
+		return r.Sample(x, 0) == key.Red
+	case r.header.ColorType == ColorTypeTruecolor:
+		return r.Sample(x, 0) == key.Red && r.Sample(x, 1) == key.Green && r.Sample(x, 2) == key.Blue
+	}
+	return false
+}
+
+// Bytes is the raw scanline without the filter type byte
+func (r RowView) Bytes() []byte {
+	return r.data
+}
+
+// Sample reads the value of a single channel of the pixel at x, without allocating
+func (r RowView) Sample(x, channel int) uint {
+	return sample(r.data, int(r.header.BitDepth), x*r.header.pixelNumChannels()+channel)
+}
+
+// sample reads the i-th sample of a scanline
+func sample(data []byte, bitDepth int, i int) uint {
+	switch bitDepth {
+	case 16:
+		return uint(binary.BigEndian.Uint16(data[i*2:]))
+	case 8:
+		return uint(data[i])
+	default:
+		// samples smaller than a byte are packed starting at the high-order bits
+		// so for bitDepth == 2 the first one is 0b11000000, the second 0b00110000, etc..
+		bit := i * bitDepth
+		shift := 8 - bitDepth - bit%8
+		return uint(data[bit/8]>>shift) & (1<<bitDepth - 1)
+	}
+}
+
+// setSample writes the i-th sample of a scanline, leaving the others untouched
+func setSample(data []byte, bitDepth int, i int, v uint) {
+	switch bitDepth {
+	case 16:
+		binary.BigEndian.PutUint16(data[i*2:], uint16(v))
+	case 8:
+		data[i] = byte(v)
+	default:
+		bit := i * bitDepth
+		shift := 8 - bitDepth - bit%8
+		mask := byte(1<<bitDepth-1) << shift
+		data[bit/8] = data[bit/8]&^mask | byte(v)<<shift&mask
+	}
+}
+
+// Pixel allocates the pixel at x, prefer Sample for large images
+func (r RowView) Pixel(x int) Pixel {
+	var pixel Pixel
+	switch r.header.ColorType {
+	case ColorTypeGrayscale:
+		pixel = &GreyscalePixel{
+			Value: r.Sample(x, 0),
+		}
+	case ColorTypeTruecolor:
+		pixel = &TruecolorPixel{
+			Red:   r.Sample(x, 0),
+			Green: r.Sample(x, 1),
+			Blue:  r.Sample(x, 2),
+			Alpha: 1<<r.header.BitDepth - 1,
+		}
+	case ColorTypePalette:
+		pixel = &PalettePixel{
+			Index: r.Sample(x, 0),
+		}
+	case ColorTypeGrayscaleAlpha:
+		pixel = &GreyscaleAlphaPixel{
+			Value: r.Sample(x, 0),
+			Alpha: r.Sample(x, 1),
+		}
+	case ColorTypeTruecolorAlpha:
+		pixel = &TruecolorPixel{
+			Red:   r.Sample(x, 0),
+			Green: r.Sample(x, 1),
+			Blue:  r.Sample(x, 2),
+			Alpha: r.Sample(x, 3),
+		}
+	}
+	return pixel
+}
+
+// DecodeRows streams the png in r, calling fn with every unfiltered scanline in
+// order. Only two scanlines are held in memory at any time, so it's suited for
+// images too big for DecodePng. Interlaced images are the exception, their
+// passes are decoded into a full image first, since any row needs all of them.
+// Decoding stops at the first error returned by fn.
+func DecodeRows(r io.Reader, fn func(y int, row RowView) error) error {
+	sig := make([]byte, 8)
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return fmt.Errorf("couldn't read PNG signature: %w", err)
+	}
+	if _, err := readSig(sig); err != nil {
+		return err
+	}
+
+	chunk, err := readChunkFrom(r)
+	if err != nil {
+		return err
+	}
+	if chunk.chunkType != IHDR {
+		return fmt.Errorf("first chunk should be IHDR, found: %s", chunk.chunkType)
+	}
+	header, err := decodeIHDRChunk(chunk.data)
+	if err != nil {
+		return err
+	}
+	var plteData PLTEData
+	var transparent *TruecolorPixel
+	for {
+		chunk, err = readChunkFrom(r)
+		if err != nil {
+			return err
+		}
+		if chunk.chunkType == IDAT {
+			break
+		}
+		switch chunk.chunkType {
+		case PLTE:
+			plteData, err = decodePLTEChunk(header, chunk.data)
+			if err != nil {
+				return err
+			}
+		case TRNS:
+			plteData, transparent, err = decodeTRNSChunk(header, plteData, chunk.data)
+			if err != nil {
+				return err
+			}
+		case IEND:
+			return fmt.Errorf("there should be atleast one IDAT chunk")
+		}
+	}
+	if header.ColorType == ColorTypePalette && len(plteData.Entries) == 0 {
+		return fmt.Errorf("palette color type missing PLTR chunk")
+	}
+
+	idat := &idatReader{r: r, data: chunk.data}
+	zr, err := zlib.NewReader(idat)
+	if err != nil {
+		return fmt.Errorf("couldn't uncompress IDAT data: %w", err)
+	}
+	defer zr.Close()
+
+	view := RowView{header: header, palette: plteData.Entries, transparent: transparent}
+	if header.InterlaceMethod == InterlaceMethodAdam7 {
+		err = decodeInterlacedRows(zr, view, fn)
+	} else {
+		err = decodeRows(zr, view, fn)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the zlib stream can end before the IDAT chunks holding it do
+	if _, err := io.Copy(io.Discard, idat); err != nil {
+		return err
+	}
+	chunk = idat.next
+	for chunk == nil || chunk.chunkType != IEND {
+		chunk, err = readChunkFrom(r)
+		if err != nil {
+			return err
+		}
+		if chunk.chunkType == IDAT {
+			return errors.New("IDAT chunks should be consecutive")
+		}
+	}
+
+	return nil
+}
+
+// decodeRows calls fn with view showing every scanline in turn
+func decodeRows(zr io.Reader, view RowView, fn func(y int, row RowView) error) error {
+	header := view.header
+	scanlineByteSize := header.scanlineByteSize()
+	pixelByteSize := header.pixelByteSize()
+	unfData := make([]byte, scanlineByteSize+1)
+	prevScanline := &Scanline{data: make([]byte, scanlineByteSize)}
+	scanline := &Scanline{}
+	for y := 0; y < header.Height; y++ {
+		if _, err := io.ReadFull(zr, unfData); err != nil {
+			return fmt.Errorf("couldn't parse IDAT data, not enough data to read all scanlines, expected: %d scanlines, got: %d: %w", header.Height, y, err)
+		}
+		scanline.index = y
+		scanline.filterType = FilterType(unfData[0])
+		scanline.unfData = unfData[1:]
+		err := unfilterScanline(prevScanline, scanline, scanlineByteSize, pixelByteSize)
+		if err != nil {
+			return err
+		}
+
+		view.data = scanline.data
+		if err := fn(y, view); err != nil {
+			return err
+		}
+		prevScanline, scanline = scanline, prevScanline
+	}
+
+	// reading to the end also checks the zlib checksum
+	n, err := io.Copy(io.Discard, zr)
+	if err != nil {
+		return fmt.Errorf("couldn't uncompress IDAT data: %w", err)
+	}
+	if n > 0 {
+		return fmt.Errorf("there was data left in the IDAT chunks after reading all scanlines, remaining bytes: %d", n)
+	}
+	return nil
+}
+
+func decodeInterlacedRows(zr io.Reader, view RowView, fn func(y int, row RowView) error) error {
+	header := view.header
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return fmt.Errorf("couldn't uncompress IDAT data: %w", err)
+	}
+	scanlines, read, err := deinterlaceAdam7(context.Background(), data, header)
+	if err != nil {
+		return err
+	}
+	if len(data[read:]) > 0 {
+		return fmt.Errorf("there was data left in the IDAT chunks after reading all scanlines, remaining bytes: %d", len(data[read:]))
+	}
+
+	for y, sl := range scanlines {
+		view.data = sl.data
+		if err := fn(y, view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idatReader reads the data of consecutive IDAT chunks as a single stream, the
+// first chunk after them is kept in next
+type idatReader struct {
+	r    io.Reader
+	data []byte
+	next *Chunk
+}
+
+func (r *idatReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if r.next != nil {
+			return 0, io.EOF
+		}
+		chunk, err := readChunkFrom(r.r)
+		if err != nil {
+			return 0, err
+		}
+		if chunk.chunkType != IDAT {
+			r.next = chunk
+			return 0, io.EOF
+		}
+		r.data = chunk.data
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func readChunkFrom(r io.Reader) (*Chunk, error) {
+	lengthAndType := make([]byte, 8)
+	if _, err := io.ReadFull(r, lengthAndType); err != nil {
+		return nil, fmt.Errorf("couldn't read chunk: %w", err)
+	}
+	length := binary.BigEndian.Uint32(lengthAndType[:4])
+	if length > math.MaxInt32 {
+		return nil, fmt.Errorf("chunk length too big: %d", length)
+	}
+
+	// the length can't be trusted, the buffer only grows as the data comes in
+	buf := bytes.NewBuffer(lengthAndType)
+	n, err := buf.ReadFrom(io.LimitReader(r, int64(length)+4))
+	if err == nil && n < int64(length)+4 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s chunk: %w", ChunkType(lengthAndType[4:]), err)
+	}
+
+	chunk, _, err := readChunk(buf.Bytes())
+	return chunk, err
+}
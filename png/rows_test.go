@@ -0,0 +1,126 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeRowsMatchesDecodePng(t *testing.T) {
+	expected, err := DecodePng(REAL_PNG)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	rows := 0
+	err = DecodeRows(bytes.NewReader(REAL_PNG), func(y int, row RowView) error {
+		if y != rows {
+			t.Fatalf("Expected row %d, but got: %d", rows, y)
+		}
+		for x := 0; x < row.Width(); x++ {
+			if !reflect.DeepEqual(row.Pixel(x), expected.Pixels[y][x]) {
+				t.Fatalf("pixel (%d, %d) expected %v, but got: %v", x, y, expected.Pixels[y][x], row.Pixel(x))
+			}
+		}
+		rows++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if rows != expected.Height {
+		t.Fatalf("Expected %d rows, but got: %d", expected.Height, rows)
+	}
+}
+
+func TestDecodeRowsSubByteSamples(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	img := image.NewPaletted(image.Rect(0, 0, 21, 9), palette)
+	for y := 0; y < 9; y++ {
+		for x := 0; x < 21; x++ {
+			img.SetColorIndex(x, y, uint8((x*y+x)%3%2))
+		}
+	}
+	err := DecodeRows(bytes.NewReader(encodeTestImage(t, img)), func(y int, row RowView) error {
+		if row.ColorType() != ColorTypePalette || row.BitDepth() != 1 {
+			t.Fatalf("Expected 1 bit palette, but got: color type %d bit depth %d", row.ColorType(), row.BitDepth())
+		}
+		for x := 0; x < row.Width(); x++ {
+			if got := row.Sample(x, 0); got != uint(img.ColorIndexAt(x, y)) {
+				t.Fatalf("pixel (%d, %d) expected index %d, but got: %d", x, y, img.ColorIndexAt(x, y), got)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+}
+
+func TestDecodeRowsStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := DecodeRows(bytes.NewReader(REAL_PNG), func(y int, row RowView) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("Expected callback error, but got: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected a single call, but got: %d", calls)
+	}
+}
+
+// pngWithIDAT is a 2x2 8 bit grayscale png with idat as its image data
+func pngWithIDAT(t *testing.T, idat []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.Write(binary.BigEndian.AppendUint64(nil, FILE_SIGN))
+	header := IHDRData{Width: 2, Height: 2, BitDepth: 8, ColorType: ColorTypeGrayscale}
+	for _, chunk := range []struct {
+		chunkType ChunkType
+		data      []byte
+	}{{IHDR, encodeIHDRChunk(header)}, {IDAT, idat}, {IEND, nil}} {
+		if err := writeChunk(&buf, chunk.chunkType, chunk.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeRowsChecksZlibStream(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte{0, 1, 2, 0, 3, 4})
+	zw.Close()
+	data := compressed.Bytes()
+
+	noop := func(y int, row RowView) error { return nil }
+	if err := DecodeRows(bytes.NewReader(pngWithIDAT(t, data)), noop); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	// flip a bit of the adler-32 checksum at the end
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-1] ^= 1
+	if err := DecodeRows(bytes.NewReader(pngWithIDAT(t, corrupt)), noop); !errors.Is(err, zlib.ErrChecksum) {
+		t.Fatalf("Expected %v, but got: %v", zlib.ErrChecksum, err)
+	}
+	if err := DecodeRows(bytes.NewReader(pngWithIDAT(t, data[:len(data)-2])), noop); err == nil {
+		t.Fatal("Expected error due to truncated zlib stream, but got no error")
+	}
+}
+
+func TestReadChunkFromTruncated(t *testing.T) {
+	// a chunk claiming 2GB with only a few bytes behind it
+	data := []byte{0x7F, 0xFF, 0xFF, 0xF0, 'I', 'D', 'A', 'T', 1, 2, 3}
+	if _, err := readChunkFrom(bytes.NewReader(data)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected %v, but got: %v", io.ErrUnexpectedEOF, err)
+	}
+}